@@ -3,7 +3,6 @@ package main
 import (
 	"flag"
 	"fmt"
-	"html/template"
 	"net/http"
 	"os"
 	"path/filepath"
@@ -11,6 +10,13 @@ import (
 	"github.com/frodi-karlsson/yaml_tmpl"
 )
 
+// Options that make the output a complete HTML document.
+var documentOptions = []yaml_tmpl.RenderOption{
+	yaml_tmpl.WithDoctype(),
+	yaml_tmpl.WithLang("en"),
+	yaml_tmpl.WithCharset("utf-8"),
+}
+
 func main() {
 	static := flag.Bool("static", false, "Build static page")
 	port := flag.String("port", "8080", "Port to listen on")
@@ -36,15 +42,15 @@ func startServer(port *string) {
 			return
 		}
 
-		template, templateData, err := loadTemplate(path, "index", string(stylesCss))
+		template, templateData, err := loadTemplate(path, string(stylesCss))
 		if err != nil {
 			http.Error(w, fmt.Sprintf("Failed to load template: %v", err), http.StatusInternalServerError)
 			return
 		}
 
-		err = template.Execute(w, templateData)
+		err = template.Render(w, templateData, documentOptions...)
 		if err != nil {
-			http.Error(w, fmt.Sprintf("Failed to execute template: %v", err), http.StatusInternalServerError)
+			http.Error(w, fmt.Sprintf("Failed to render template: %v", err), http.StatusInternalServerError)
 			return
 		}
 	})
@@ -114,7 +120,7 @@ func buildStatic() error {
 
 	for _, file := range files {
 		// Write yaml
-		template, templateData, err := loadTemplate(file, filepath.Base(file), string(styleCss))
+		template, templateData, err := loadTemplate(file, string(styleCss))
 		if err != nil {
 			return fmt.Errorf("BuildStatic failed to load template: %w", err)
 		}
@@ -126,9 +132,11 @@ func buildStatic() error {
 			return fmt.Errorf("BuildStatic failed to create file: %w", err)
 		}
 
-		err = template.Execute(out, templateData)
+		// Indent the static build, so that changes to it are easy to review.
+		options := append([]yaml_tmpl.RenderOption{yaml_tmpl.WithIndent("  ")}, documentOptions...)
+		err = template.Render(out, templateData, options...)
 		if err != nil {
-			return fmt.Errorf("BuildStatic failed to execute template: %w", err)
+			return fmt.Errorf("BuildStatic failed to render template: %w", err)
 		}
 
 		out.Close()
@@ -137,17 +145,17 @@ func buildStatic() error {
 	return nil
 }
 
-// Load a template from a file and return a parsed template and the data to be used with it.
+// Load a template from a file and return it along with the data to render it with.
 // You can also pass in a CSS string to be used in the template.
-func loadTemplate(path string, name string, css string) (*template.Template, interface{}, error) {
+func loadTemplate(path string, css string) (*yaml_tmpl.Template, any, error) {
 	rawContent, err := os.ReadFile(path)
 	if err != nil {
 		return nil, nil, fmt.Errorf("LoadTemplate failed to read file: %w", err)
 	}
 
-	loaded, err := yaml_tmpl.LoadTemplate(path)
+	template, err := yaml_tmpl.ParseTemplateFS(os.DirFS(filepath.Dir(path)), filepath.Base(path))
 	if err != nil {
-		return nil, nil, fmt.Errorf("LoadTemplate failed to load template: %w", err)
+		return nil, nil, fmt.Errorf("LoadTemplate failed to parse template: %w", err)
 	}
 
 	templateData := struct {
@@ -158,10 +166,5 @@ func loadTemplate(path string, name string, css string) (*template.Template, int
 		CSS:  css,
 	}
 
-	template, err := template.New(name).Parse(loaded)
-	if err != nil {
-		return nil, nil, fmt.Errorf("LoadTemplate failed to parse template: %w", err)
-	}
-
 	return template, templateData, nil
 }
@@ -0,0 +1,248 @@
+package yaml_tmpl
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Attributes that can be given as a sequence or mapping, along with the function joining them into a string.
+var _STRUCTURED_ATTRIBUTES = map[string]func(context *transpileContext, node *YamlNode) (string, error){
+	"class": joinClassList,
+	"style": joinStyleMap,
+}
+
+// Mappings that expand into one attribute per key, prefixed with the name of the group.
+var _ATTRIBUTE_GROUPS = map[string]bool{
+	"aria": true,
+	"data": true,
+}
+
+// Determines whether a condition in a structured attribute is met. Only plain true and false,
+// or null for false, are allowed.
+func isConditionMet(node *YamlNode) (bool, error) {
+	if node.Type == RAW_YAML_NODE && node.Plain {
+		if isTrueScalar(node.Content) {
+			return true, nil
+		}
+		if isFalseScalar(node.Content) || isNullScalar(node.Content) {
+			return false, nil
+		}
+	}
+
+	return false, fmt.Errorf("the value of %s has to be true or false", node.Key)
+}
+
+// Collects the class names of a class list into names, skipping names that are already in seen.
+func collectClassNames(context *transpileContext, node *YamlNode, names []string, seen map[string]bool) ([]string, error) {
+	for _, child := range node.Children {
+		child, err := context.resolve(child, nil)
+		if err != nil {
+			return nil, err
+		}
+
+		switch {
+		case child.Key == "" && child.Type == CHILDREN_YAML_NODE:
+			// A nested list, like `class: [[a, b], c]`.
+			names, err = collectClassNames(context, child, names, seen)
+			if err != nil {
+				return nil, err
+			}
+			continue
+		case child.Key == "":
+			// An entry may hold several names, like `- "btn btn-primary"`.
+			for _, name := range strings.Fields(child.Content) {
+				if !seen[name] {
+					seen[name] = true
+					names = append(names, name)
+				}
+			}
+			continue
+		}
+
+		include, err := isConditionMet(child)
+		if err != nil {
+			return nil, err
+		}
+
+		if include && !seen[child.Key] {
+			seen[child.Key] = true
+			names = append(names, child.Key)
+		}
+	}
+
+	return names, nil
+}
+
+// Joins a class list, like `class: [btn, {active: true}]`, into a class attribute value.
+// Names are only included once, in the order they first appear.
+func joinClassList(context *transpileContext, node *YamlNode) (string, error) {
+	names, err := collectClassNames(context, node, []string{}, map[string]bool{})
+	if err != nil {
+		return "", fmt.Errorf("JoinClassList failed: %w", err)
+	}
+
+	return strings.Join(names, " "), nil
+}
+
+// The language of the values of a style map, whatever their key.
+func cssLanguage(key string) valueLanguage {
+	return _CSS_LANGUAGE
+}
+
+// Joins a style map, like `style: {color: red, margin-top: 4px}`, into a style attribute value.
+//
+// Each property is only included once, where it first appears but with the value it was last given.
+// Properties set to false or null are left out. Entries without a key are declarations, like `- "color: red"`.
+func joinStyleMap(context *transpileContext, node *YamlNode) (string, error) {
+	properties := make([]string, 0, len(node.Children))
+	values := make(map[string]string, len(node.Children))
+
+	set := func(property string, value string) {
+		if _, exists := values[property]; !exists {
+			properties = append(properties, property)
+		}
+		values[property] = value
+	}
+
+	for _, child := range node.Children {
+		child, err := context.resolve(child, cssLanguage)
+		if err != nil {
+			return "", fmt.Errorf("JoinStyleMap failed: %w", err)
+		}
+
+		if child.Type != RAW_YAML_NODE {
+			return "", fmt.Errorf("JoinStyleMap failed: the value of %s has to be a string", child.Key)
+		}
+
+		if child.Key == "" {
+			for _, declaration := range strings.Split(child.Content, ";") {
+				property, value, found := strings.Cut(declaration, ":")
+				property = strings.TrimSpace(property)
+				if !found || property == "" {
+					if strings.TrimSpace(declaration) != "" {
+						return "", fmt.Errorf("JoinStyleMap failed: %q is not a declaration", strings.TrimSpace(declaration))
+					}
+					continue
+				}
+				set(property, strings.TrimSpace(value))
+			}
+			continue
+		}
+
+		if child.Plain && (isFalseScalar(child.Content) || isNullScalar(child.Content)) {
+			// A value set to null again removes the property.
+			if _, exists := values[child.Key]; exists {
+				values[child.Key] = ""
+			}
+			continue
+		}
+
+		set(child.Key, child.Content)
+	}
+
+	declarations := make([]string, 0, len(properties))
+	for _, property := range properties {
+		if values[property] != "" {
+			declarations = append(declarations, property+": "+values[property])
+		}
+	}
+
+	return strings.Join(declarations, "; "), nil
+}
+
+// Transpiles an attribute given as a sequence or mapping, like `class: [a, b]`. Returns nil if the
+// value is empty, as in a class list where no condition is met.
+func (node *YamlNode) transpileStructuredAttribute(context *transpileContext, parent *HtmlNode) (*HtmlNode, error) {
+	value, err := _STRUCTURED_ATTRIBUTES[node.Key](context, node)
+	if err != nil {
+		return nil, fmt.Errorf("TranspileStructuredAttribute failed: %w", err)
+	}
+
+	if value == "" {
+		return nil, nil
+	}
+
+	return &HtmlNode{
+		Type:      ATTRIBUTE_HTML_NODE,
+		Attribute: node.Key,
+		Content:   value,
+		Parent:    parent,
+	}, nil
+}
+
+// Converts a key to kebab-case, like userId and user_id to user-id.
+func toKebabCase(key string) string {
+	var builder strings.Builder
+	builder.Grow(len(key) + 2)
+
+	for index := 0; index < len(key); index++ {
+		char := key[index]
+
+		switch {
+		case char == '_' || char == ' ':
+			builder.WriteByte('-')
+		case 'A' <= char && char <= 'Z':
+			previous := byte(0)
+			if index > 0 {
+				previous = key[index-1]
+			}
+			if 'a' <= previous && previous <= 'z' || '0' <= previous && previous <= '9' {
+				builder.WriteByte('-')
+			}
+			builder.WriteByte(char + 'a' - 'A')
+		default:
+			builder.WriteByte(char)
+		}
+	}
+
+	return builder.String()
+}
+
+// Collects the attributes of an attribute group, prefixing each key with prefix.
+func collectGroupAttributes(context *transpileContext, node *YamlNode, prefix string, parent *HtmlNode, attributes []*HtmlNode) ([]*HtmlNode, error) {
+	for _, child := range node.Children {
+		child, err := context.resolve(child, nil)
+		if err != nil {
+			return nil, err
+		}
+
+		if child.Key == "" {
+			return nil, fmt.Errorf("entries of %s need a key", prefix)
+		}
+
+		name := prefix + "-" + toKebabCase(child.Key)
+
+		if child.Type == CHILDREN_YAML_NODE {
+			attributes, err = collectGroupAttributes(context, child, name, parent, attributes)
+			if err != nil {
+				return nil, err
+			}
+			continue
+		}
+
+		// Null leaves the attribute out. Booleans are kept as strings, as values like aria-hidden="false" mean something.
+		if child.Plain && isNullScalar(child.Content) {
+			continue
+		}
+
+		attributes = append(attributes, &HtmlNode{
+			Type:      ATTRIBUTE_HTML_NODE,
+			Attribute: name,
+			Content:   child.Content,
+			Parent:    parent,
+		})
+	}
+
+	return attributes, nil
+}
+
+// Transpiles an attribute group, like `data: {user-id: 5}`, into one attribute per key, like data-user-id="5".
+// Nested keys are joined with a dash, and all keys are converted to kebab-case. Null values are left out.
+func (node *YamlNode) transpileAttributeGroup(context *transpileContext, parent *HtmlNode) ([]*HtmlNode, error) {
+	attributes, err := collectGroupAttributes(context, node, node.Key, parent, make([]*HtmlNode, 0, len(node.Children)))
+	if err != nil {
+		return nil, fmt.Errorf("TranspileAttributeGroup failed: %w", err)
+	}
+
+	return attributes, nil
+}
@@ -0,0 +1,202 @@
+package yaml_tmpl
+
+import (
+	"fmt"
+	"strings"
+)
+
+type chompingMode int
+
+const (
+	// Keeps a single trailing line break. This is the default.
+	_CLIP_CHOMPING chompingMode = iota
+	// Removes all trailing line breaks. Set with `|-` or `>-`.
+	_STRIP_CHOMPING
+	// Keeps all trailing line breaks. Set with `|+` or `>+`.
+	_KEEP_CHOMPING
+)
+
+// The parsed header of a block scalar, such as `|-` or `>2`.
+type blockScalarHeader struct {
+	// True for folded (>) scalars, false for literal (|) scalars.
+	folded   bool
+	chomping chompingMode
+	// The explicit indentation indicator, or 0 if the indentation should be detected.
+	indentation int
+}
+
+// Whether a value starts a block scalar, meaning that its content is on the following lines.
+func isBlockScalarHeader(value string) bool {
+	return len(value) > 0 && (value[0] == '|' || value[0] == '>')
+}
+
+// Parses the header of a block scalar. valueIndex is where the header starts in the line.
+func parseBlockScalarHeader(state *parseState, line sourceLine, value string, valueIndex int) (blockScalarHeader, error) {
+	header := blockScalarHeader{
+		folded: value[0] == '>',
+	}
+
+	hasChomping := false
+
+	for index := 1; index < len(value); index++ {
+		char := value[index]
+
+		switch {
+		case (char == '-' || char == '+') && !hasChomping:
+			hasChomping = true
+			if char == '-' {
+				header.chomping = _STRIP_CHOMPING
+			} else {
+				header.chomping = _KEEP_CHOMPING
+			}
+		case '1' <= char && char <= '9' && header.indentation == 0:
+			header.indentation = int(char - '0')
+		case char == ' ' || char == '\t':
+			// Only a comment may follow the indicators.
+			rest := strings.TrimLeft(value[index:], " \t")
+			if rest != "" && rest[0] != '#' {
+				column := valueIndex + len(value) - len(rest) + 1
+				return header, state.errorAt(line, column, "unexpected content after block scalar header")
+			}
+			return header, nil
+		default:
+			return header, state.errorAt(line, valueIndex+index+1, "invalid block scalar indicator %q", char)
+		}
+	}
+
+	return header, nil
+}
+
+// Removes count columns of indentation from a line.
+func trimIndentation(line string, count int) string {
+	removed := 0
+	for index, char := range line {
+		if removed >= count || (char != ' ' && char != '\t') {
+			return line[index:]
+		}
+		if char == '\t' {
+			removed += 4
+		} else {
+			removed++
+		}
+	}
+
+	return ""
+}
+
+// Folds the lines of a folded block scalar. Line breaks between lines of normal text become spaces,
+// while empty lines and lines that are more indented than the rest keep their line breaks.
+func foldLines(lines []string) string {
+	var builder strings.Builder
+
+	// Empty lines at the start are always kept as line breaks.
+	start := 0
+	for start < len(lines) && lines[start] == "" {
+		builder.WriteByte('\n')
+		start++
+	}
+
+	previous := -1
+	for index := start; index < len(lines); index++ {
+		line := lines[index]
+		if line == "" {
+			continue
+		}
+
+		if previous != -1 {
+			emptyLines := index - previous - 1
+			isMoreIndented := line[0] == ' ' || line[0] == '\t'
+			wasMoreIndented := lines[previous][0] == ' ' || lines[previous][0] == '\t'
+
+			if isMoreIndented || wasMoreIndented {
+				builder.WriteString(strings.Repeat("\n", emptyLines+1))
+			} else if emptyLines > 0 {
+				builder.WriteString(strings.Repeat("\n", emptyLines))
+			} else {
+				builder.WriteByte(' ')
+			}
+		}
+
+		builder.WriteString(line)
+		previous = index
+	}
+
+	return builder.String()
+}
+
+// Extracts the content of a block scalar. The first line is the definition containing the header,
+// and the remaining lines are its content.
+//
+// An explicit indentation indicator is relative to the indentation of the node the scalar is in. For a
+// key in a sequence entry, like `- p: |2`, that's the column of the key, and for a sequence entry without
+// a key, like `- |2`, that's the column of the dash.
+func extractBlockScalar(state *parseState, lines []sourceLine, value string, valueIndex int) (string, error) {
+	definition := lines[0]
+
+	header, err := parseBlockScalarHeader(state, definition, value, valueIndex)
+	if err != nil {
+		return "", fmt.Errorf("ExtractBlockScalar failed: %w", err)
+	}
+
+	contentLines := lines[1:]
+
+	contentIndentation := 0
+	if header.indentation != 0 {
+		parentIndentation := keyColumn(definition.text) - 1
+		if findMappingColon(definition.text) == -1 {
+			parentIndentation = getIndentation(definition.text)
+		}
+		contentIndentation = parentIndentation + header.indentation
+	} else {
+		for _, line := range contentLines {
+			if !isBlank(line.text) {
+				contentIndentation = getIndentation(line.text)
+				break
+			}
+		}
+	}
+
+	content := make([]string, 0, len(contentLines))
+	lastNonEmpty := -1
+
+	for index, line := range contentLines {
+		if isBlank(line.text) {
+			content = append(content, "")
+			continue
+		}
+
+		if getIndentation(line.text) < contentIndentation {
+			return "", fmt.Errorf("ExtractBlockScalar failed: %w", state.errorAt(line, keyColumn(line.text), "line is indented less than the block scalar content"))
+		}
+
+		content = append(content, trimIndentation(line.text, contentIndentation))
+		lastNonEmpty = index
+	}
+
+	body := content[:lastNonEmpty+1]
+	trailingLines := len(content) - len(body)
+
+	var result string
+	if header.folded {
+		result = foldLines(body)
+	} else {
+		result = strings.Join(body, "\n")
+	}
+
+	if lastNonEmpty == -1 {
+		// A scalar without content has no line breaks to clip.
+		if header.chomping == _KEEP_CHOMPING {
+			return strings.Repeat("\n", trailingLines), nil
+		}
+		return "", nil
+	}
+
+	switch header.chomping {
+	case _STRIP_CHOMPING:
+		return result, nil
+	case _KEEP_CHOMPING:
+		return result + strings.Repeat("\n", trailingLines+1), nil
+	default:
+		return result + "\n", nil
+	}
+}
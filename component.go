@@ -0,0 +1,368 @@
+package yaml_tmpl
+
+import (
+	"fmt"
+	"reflect"
+	"strconv"
+	"strings"
+)
+
+// The key of a top level node declaring a component.
+const _COMPONENT_KEY = "component"
+
+// The name of the slot that the children a component is given are put in.
+const _CHILDREN_SLOT = "children"
+
+// Checks the value of a prop against its type, by type name.
+var _PROP_TYPES = map[string]func(value reflect.Value) bool{
+	"string": func(value reflect.Value) bool { return value.Kind() == reflect.String },
+	"number": func(value reflect.Value) bool { return isNumberKind(value.Kind()) },
+	"bool":   func(value reflect.Value) bool { return value.Kind() == reflect.Bool },
+	"list":   func(value reflect.Value) bool { return value.Kind() == reflect.Slice || value.Kind() == reflect.Array },
+	"map":    func(value reflect.Value) bool { return value.Kind() == reflect.Map || value.Kind() == reflect.Struct },
+	"any":    func(value reflect.Value) bool { return true },
+}
+
+// A prop that a component can be given.
+type componentProp struct {
+	name string
+	// The name of its type, one of _PROP_TYPES.
+	kind string
+	// Whether the prop has to be given. A prop with a default doesn't.
+	required bool
+	// The value of the prop when it isn't given.
+	fallback any
+}
+
+// A reusable piece of a template, declared with component: and used like a tag.
+type component struct {
+	name  string
+	props []componentProp
+	// The nodes the component is replaced with.
+	body []*YamlNode
+}
+
+// A use of a component, while its body is being transpiled.
+type componentCall struct {
+	component *component
+	// What the component was given for its children slot, or nil if nothing.
+	children []*YamlNode
+	// The context the component was used in, which its children are transpiled in.
+	caller *transpileContext
+}
+
+// Determines whether a node declares a component.
+func (node *YamlNode) isComponent() bool {
+	return node.Key == _COMPONENT_KEY
+}
+
+// Returns the value of a scalar that isn't an expression. Only plain scalars can be booleans, null or numbers.
+func scalarValue(content string, plain bool) any {
+	if !plain {
+		return content
+	}
+
+	switch {
+	case isNullScalar(content):
+		return nil
+	case isTrueScalar(content):
+		return true
+	case isFalseScalar(content):
+		return false
+	}
+
+	if integer, err := strconv.ParseInt(content, 10, 64); err == nil {
+		return integer
+	}
+	if float, err := strconv.ParseFloat(content, 64); err == nil {
+		return float
+	}
+
+	return content
+}
+
+// Returns the value of a prop given to a component. A single expression keeps the type of its value, and
+// sequences and mappings are lists and maps. Scalars are strings for string props.
+func (context *transpileContext) propValue(node *YamlNode, kind string) (any, error) {
+	if node.Type == RAW_YAML_NODE {
+		value, ok, err := context.evaluateSingle(node.Content)
+		if err != nil || ok {
+			return value, err
+		}
+
+		content, _, err := context.resolveScalar(node.Content, _HTML_LANGUAGE)
+		if err != nil || kind == "string" {
+			return content, err
+		}
+
+		return scalarValue(content, node.Plain), nil
+	}
+
+	isList := kind != "map"
+	for _, child := range node.Children {
+		if child.Key != "" {
+			isList = false
+		}
+	}
+
+	if isList {
+		list := make([]any, len(node.Children))
+		for index, child := range node.Children {
+			value, err := context.propValue(child, "any")
+			if err != nil {
+				return nil, err
+			}
+			list[index] = value
+		}
+		return list, nil
+	}
+
+	values := make(map[string]any, len(node.Children))
+	for _, child := range node.Children {
+		value, err := context.propValue(child, "any")
+		if err != nil {
+			return nil, err
+		}
+		values[child.Key] = value
+	}
+
+	return values, nil
+}
+
+// Checks a value given for a prop against its type. Nil is only allowed for props of type any.
+func (prop *componentProp) check(value any) error {
+	reflected, ok := indirect(reflect.ValueOf(value))
+	if prop.kind == "any" || ok && reflected.IsValid() && _PROP_TYPES[prop.kind](reflected) {
+		return nil
+	}
+
+	return fmt.Errorf("prop %s has to be a %s, not %T", prop.name, prop.kind, value)
+}
+
+// Parses the declaration of a prop, which is either its type, like `title: string`, or a mapping
+// with its type: and a default:.
+func parseProp(node *YamlNode) (componentProp, error) {
+	prop := componentProp{name: node.Key, required: true}
+	var fallback *YamlNode
+
+	if !isValidVariableName(node.Key) {
+		return prop, fmt.Errorf("%s: %q is not a valid prop name", node.Position, node.Key)
+	}
+
+	if node.Type == RAW_YAML_NODE {
+		prop.kind = strings.TrimSpace(node.Content)
+	}
+
+	for _, child := range node.Children {
+		switch child.Key {
+		case "type":
+			prop.kind = strings.TrimSpace(child.Content)
+		case "default":
+			fallback = child
+		default:
+			return prop, fmt.Errorf("%s: a prop can only have a type: and a default:, not %s", child.Position, child.Key)
+		}
+	}
+
+	if _PROP_TYPES[prop.kind] == nil {
+		return prop, fmt.Errorf("%s: prop %s needs a type, one of string, number, bool, list, map or any", node.Position, node.Key)
+	}
+
+	if fallback == nil {
+		return prop, nil
+	}
+
+	if hasExpression(fallback.Content) {
+		return prop, fmt.Errorf("%s: the default of prop %s can't be an expression", fallback.Position, node.Key)
+	}
+
+	value, err := (&transpileContext{}).propValue(fallback, prop.kind)
+	if err == nil && value != nil {
+		err = prop.check(value)
+	}
+	if err != nil {
+		return prop, fmt.Errorf("%s: %w", fallback.Position, err)
+	}
+
+	prop.required = false
+	prop.fallback = value
+	return prop, nil
+}
+
+// Parses a component: declaration. Everything but its name: and props: is its body, so the name is
+// given as name: rather than as the value of component:.
+//
+//	component:
+//	  name: card
+//	  props:
+//	    title: string
+//	    featured: {type: bool, default: false}
+//	  article:
+//	    class: {card: true, featured: ${ .featured }}
+//	    children:
+//	      - h2: ${ .title }
+//	      - slot: children
+func parseComponent(node *YamlNode) (*component, error) {
+	if node.Type == RAW_YAML_NODE {
+		return nil, fmt.Errorf("%s: component needs its name:, props: and body in a mapping, like component: {name: %s, props: {...}, div: ...}", node.Position, strings.TrimSpace(node.Content))
+	}
+
+	declared := &component{}
+
+	for _, child := range node.Children {
+		switch child.Key {
+		case "name":
+			declared.name = strings.TrimSpace(child.Content)
+		case "props":
+			for _, propNode := range child.Children {
+				prop, err := parseProp(propNode)
+				if err != nil {
+					return nil, err
+				}
+				declared.props = append(declared.props, prop)
+			}
+		default:
+			declared.body = append(declared.body, child)
+		}
+	}
+
+	if !isValidName(declared.name) || hasExpression(declared.name) {
+		return nil, fmt.Errorf("%s: component needs a name: that is a valid tag name, like card", node.Position)
+	}
+
+	return declared, nil
+}
+
+// Collects the component: declarations among top level nodes, returning them by name along with the other nodes.
+func collectComponents(nodes []YamlNode) (map[string]*component, []YamlNode, error) {
+	components := make(map[string]*component)
+	rest := make([]YamlNode, 0, len(nodes))
+
+	for index := range nodes {
+		node := &nodes[index]
+		if !node.isComponent() {
+			rest = append(rest, *node)
+			continue
+		}
+
+		declared, err := parseComponent(node)
+		if err != nil {
+			return nil, nil, err
+		}
+
+		if _, exists := components[declared.name]; exists {
+			return nil, nil, fmt.Errorf("%s: component %s is declared more than once", node.Position, declared.name)
+		}
+		components[declared.name] = declared
+	}
+
+	adoptChildren(rest)
+	return components, rest, nil
+}
+
+// Finds a prop of a component by name.
+func (declared *component) findProp(name string) *componentProp {
+	for index := range declared.props {
+		if declared.props[index].name == name {
+			return &declared.props[index]
+		}
+	}
+
+	return nil
+}
+
+// Collects the props a component is given, along with what it's given for its children slot.
+func (declared *component) collectProps(context *transpileContext, node *YamlNode) (map[string]any, []*YamlNode, error) {
+	props := make(map[string]any, len(declared.props))
+	var children []*YamlNode
+
+	for _, child := range node.Children {
+		if child.Key == _CHILDREN_SLOT {
+			if child.Type == RAW_YAML_NODE {
+				children = []*YamlNode{{Type: RAW_YAML_NODE, Content: child.Content, Plain: child.Plain, Parent: node, Position: child.Position}}
+			} else {
+				children = child.Children
+			}
+			continue
+		}
+
+		prop := declared.findProp(child.Key)
+		if prop == nil {
+			return nil, nil, fmt.Errorf("%s: component %s has no prop %s", child.Position, declared.name, child.Key)
+		}
+
+		value, err := context.propValue(child, prop.kind)
+		if err != nil {
+			return nil, nil, fmt.Errorf("%s: %w", child.Position, err)
+		}
+
+		if value == nil && !prop.required {
+			continue
+		}
+
+		err = prop.check(value)
+		if err != nil {
+			return nil, nil, fmt.Errorf("%s: component %s: %w", child.Position, declared.name, err)
+		}
+		props[prop.name] = value
+	}
+
+	for _, prop := range declared.props {
+		if _, given := props[prop.name]; given {
+			continue
+		}
+
+		if prop.required {
+			return nil, nil, fmt.Errorf("%s: component %s needs prop %s", node.Position, declared.name, prop.name)
+		}
+		props[prop.name] = prop.fallback
+	}
+
+	return props, children, nil
+}
+
+// Transpiles the use of a component, which is replaced with its body. Inside the body, the dot is the props
+// the component is given, and $ is still the data the template is rendered with.
+func (node *YamlNode) transpileComponent(context *transpileContext, declared *component, parent *HtmlNode) ([]*HtmlNode, error) {
+	if node.Type == RAW_YAML_NODE && node.Content != "" {
+		return nil, fmt.Errorf("TranspileComponent failed: %s: component %s has to be given its props as a mapping", node.Position, declared.name)
+	}
+
+	for call := context.call; call != nil; call = call.caller.call {
+		if call.component == declared {
+			return nil, fmt.Errorf("TranspileComponent failed: %s: component %s can't use itself", node.Position, declared.name)
+		}
+	}
+
+	props, children, err := declared.collectProps(context, node)
+	if err != nil {
+		return nil, fmt.Errorf("TranspileComponent failed: %w", err)
+	}
+
+	inner := *context
+	inner.data = props
+	inner.variables = nil
+	inner.call = &componentCall{component: declared, children: children, caller: context}
+
+	return transpileSiblings(&inner, declared.body, parent, true)
+}
+
+// Transpiles a slot: in the body of a component, which is replaced with the children the component is given,
+// or with its default content if it isn't given any.
+func (node *YamlNode) transpileComponentSlot(context *transpileContext, parent *HtmlNode, asElement bool) ([]*HtmlNode, error) {
+	name, content, err := node.splitSlot()
+	if err != nil {
+		return nil, fmt.Errorf("TranspileComponentSlot failed: %w", err)
+	}
+
+	if name != _CHILDREN_SLOT {
+		return nil, fmt.Errorf("TranspileComponentSlot failed: %s: a component only has a %s slot, not %s", node.Position, _CHILDREN_SLOT, name)
+	}
+
+	call := context.call
+	if call.children == nil {
+		return transpileSiblings(context, content, parent, asElement)
+	}
+
+	return transpileSiblings(call.caller, call.children, parent, asElement)
+}
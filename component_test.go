@@ -0,0 +1,153 @@
+package yaml_tmpl_test
+
+import (
+	"strings"
+	"testing"
+	"testing/fstest"
+
+	"github.com/frodi-karlsson/yaml_tmpl"
+)
+
+var COMPONENT_TEMPLATE = []string{
+	"component:",
+	"  name: card",
+	"  props:",
+	"    title: string",
+	"    count: {type: number, default: 0}",
+	"    featured:",
+	"      type: bool",
+	"      default: false",
+	"    tags: {type: list, default: []}",
+	"  article:",
+	"    class: {card: true, featured: ${ .featured }}",
+	"    children:",
+	"      - h2: ${ .title } (${ .count })",
+	"      - each:",
+	"          in: ${ .tags }",
+	"          span: ${ . }",
+	"      - slot:",
+	"          name: children",
+	"          p: No content",
+	"      - small: ${ $.site }",
+	"main:",
+	"  children:",
+	"    - card:",
+	"        title: First",
+	"        featured: true",
+	"        count: 2",
+	"        tags: [a, b]",
+	"        children:",
+	"          - p: ${ .intro }",
+	"    - each:",
+	"        in: ${ .posts }",
+	"        as: post",
+	"        card:",
+	"          title: ${ $post.Title }",
+	"          tags: ${ $post.Tags }",
+}
+
+type componentPost struct {
+	Title string
+	Tags  []string
+}
+
+func TestComponents(t *testing.T) {
+	html, err := renderLines(t, COMPONENT_TEMPLATE, map[string]any{
+		"site":  "Blog",
+		"intro": "Hello",
+		"posts": []componentPost{{Title: "Second", Tags: []string{"c"}}},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := "<main>" +
+		"<article class=\"card featured\"><h2>First (2)</h2><span>a</span><span>b</span><p>Hello</p><small>Blog</small></article>" +
+		"<article class=\"card\"><h2>Second (0)</h2><span>c</span><p>No content</p><small>Blog</small></article>" +
+		"</main>"
+	if html != expected {
+		t.Errorf("Expected %s, got %s", expected, html)
+	}
+}
+
+func TestComponentErrors(t *testing.T) {
+	declaration := []string{
+		"component:",
+		"  name: badge",
+		"  props:",
+		"    label: string",
+		"    level: {type: number, default: 1}",
+		"  span: ${ .label }",
+	}
+
+	tests := []struct {
+		lines   []string
+		message string
+	}{
+		{[]string{"badge:", "  label: New", "  color: red"}, "component badge has no prop color"},
+		{[]string{"badge:", "  level: 2"}, "component badge needs prop label"},
+		{[]string{"badge:", "  label: New", "  level: high"}, "prop level has to be a number, not string"},
+		{[]string{"badge:", "  label: ${ .count }"}, "prop label has to be a string, not int"},
+		{[]string{"badge: New"}, "has to be given its props as a mapping"},
+	}
+
+	for _, test := range tests {
+		_, err := renderLines(t, append(append([]string{}, declaration...), test.lines...), map[string]any{"count": 1})
+		if err == nil || !strings.Contains(err.Error(), test.message) {
+			t.Errorf("Expected an error containing %q for %v, got %v", test.message, test.lines, err)
+		}
+	}
+}
+
+func TestComponentDeclarationErrors(t *testing.T) {
+	tests := []struct {
+		lines   []string
+		message string
+	}{
+		{[]string{"component:", "  p: a"}, "component needs a name:"},
+		{[]string{"component: card"}, "component needs its name:, props: and body in a mapping, like component: {name: card"},
+		{[]string{"component:", "  name: a", "  props:", "    x: text"}, "prop x needs a type"},
+		{[]string{"component:", "  name: a", "  props:", "    x: {type: number, default: many}"}, "prop x has to be a number"},
+		{[]string{"component:", "  name: a", "  props:", "    x: {type: any, default: ${ .y }}"}, "can't be an expression"},
+		{[]string{"component:", "  name: a", "  p: x", "component:", "  name: a", "  p: y"}, "component a is declared more than once"},
+		{[]string{"component:", "  name: a", "  div:", "    children:", "      - a: {}", "a: {}"}, "component a can't use itself"},
+		{[]string{"component:", "  name: a", "  slot: header", "a: {}"}, "a component only has a children slot"},
+	}
+
+	for _, test := range tests {
+		_, err := renderLines(t, test.lines, nil)
+		if err == nil || !strings.Contains(err.Error(), test.message) {
+			t.Errorf("Expected an error containing %q for %v, got %v", test.message, test.lines, err)
+		}
+	}
+}
+
+func TestComponentsAcrossFiles(t *testing.T) {
+	fsys := fstest.MapFS{
+		"components.yaml": {
+			Data: []byte("component:\n  name: greeting\n  props:\n    name: string\n  p: Hello ${ .name }\n"),
+		},
+		"layout.yaml": {
+			Data: []byte("body:\n  children:\n    - slot: main\n"),
+		},
+		"page.yaml": {
+			Data: []byte("include: components.yaml\nlayout: layout.yaml\nslots:\n  main:\n    - greeting: {name: Ada}\n"),
+		},
+	}
+
+	template, err := yaml_tmpl.ParseTemplateFS(fsys, "page.yaml")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var out strings.Builder
+	err = template.Render(&out, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := "<body><p>Hello Ada</p></body>"
+	if out.String() != expected {
+		t.Errorf("Expected %s, got %s", expected, out.String())
+	}
+}
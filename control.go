@@ -0,0 +1,281 @@
+package yaml_tmpl
+
+import (
+	"cmp"
+	"fmt"
+	"reflect"
+	"slices"
+)
+
+// An item of a collection being looped over.
+type loopItem struct {
+	// The index of the item, or its key in a map.
+	index any
+	value any
+}
+
+// Compares map keys, so that maps are looped over in a stable order.
+func compareKeys(a reflect.Value, b reflect.Value) int {
+	switch a.Kind() {
+	case reflect.String:
+		return cmp.Compare(a.String(), b.String())
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return cmp.Compare(a.Int(), b.Int())
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		return cmp.Compare(a.Uint(), b.Uint())
+	case reflect.Float32, reflect.Float64:
+		return cmp.Compare(a.Float(), b.Float())
+	}
+
+	return cmp.Compare(fmt.Sprint(a.Interface()), fmt.Sprint(b.Interface()))
+}
+
+// Lists the items of a collection. Slices and arrays are listed in order, maps by sorted key,
+// and an integer n counts from 0 to n-1. Nil has no items.
+func listItems(collection any) ([]loopItem, error) {
+	value, ok := indirect(reflect.ValueOf(collection))
+	if !ok || !value.IsValid() {
+		return []loopItem{}, nil
+	}
+
+	switch value.Kind() {
+	case reflect.Slice, reflect.Array:
+		items := make([]loopItem, 0, value.Len())
+		for index := 0; index < value.Len(); index++ {
+			items = append(items, loopItem{index: index, value: value.Index(index).Interface()})
+		}
+		return items, nil
+	case reflect.Map:
+		keys := value.MapKeys()
+		slices.SortFunc(keys, compareKeys)
+
+		items := make([]loopItem, 0, len(keys))
+		for _, key := range keys {
+			items = append(items, loopItem{index: key.Interface(), value: value.MapIndex(key).Interface()})
+		}
+		return items, nil
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		items := make([]loopItem, 0, max(value.Int(), 0))
+		for index := 0; index < int(value.Int()); index++ {
+			items = append(items, loopItem{index: index, value: index})
+		}
+		return items, nil
+	}
+
+	return nil, fmt.Errorf("can't loop over %s", value.Type())
+}
+
+// Transpiles an each: loop, which repeats its body for every item of a collection.
+//
+//	each:
+//	  in: ${ .products }
+//	  as: product
+//	  index: i
+//	  li: ${ $product.Name }
+//
+// Everything but in, as and index is the body. Inside it, the dot is the item, and the item and index are
+// bound to the variables named by as and index. The nodes of each iteration are spliced into the parent,
+// as elements or attributes depending on where the loop is.
+func (node *YamlNode) transpileEach(context *transpileContext, parent *HtmlNode, asElement bool) ([]*HtmlNode, error) {
+	var collection any
+	hasCollection := false
+	itemName := ""
+	indexName := ""
+	body := make([]*YamlNode, 0, len(node.Children))
+
+	for _, child := range node.Children {
+		switch child.Key {
+		case "in":
+			if child.Type != RAW_YAML_NODE {
+				return nil, fmt.Errorf("TranspileEach failed: %s: in has to be a single expression, like ${ .items }", child.Position)
+			}
+
+			value, ok, err := context.evaluateSingle(child.Content)
+			if err != nil {
+				return nil, fmt.Errorf("TranspileEach failed: %s: %w", child.Position, err)
+			}
+			if !ok {
+				return nil, fmt.Errorf("TranspileEach failed: %s: in has to be a single expression, like ${ .items }", child.Position)
+			}
+
+			collection = value
+			hasCollection = true
+		case "as", "index":
+			if child.Type != RAW_YAML_NODE || !isValidVariableName(child.Content) {
+				return nil, fmt.Errorf("TranspileEach failed: %s: %s has to be a variable name, like item", child.Position, child.Key)
+			}
+
+			if child.Key == "as" {
+				itemName = child.Content
+			} else {
+				indexName = child.Content
+			}
+		default:
+			body = append(body, child)
+		}
+	}
+
+	if !hasCollection {
+		return nil, fmt.Errorf("TranspileEach failed: %s: each needs an in: with the collection to loop over", node.Position)
+	}
+
+	items, err := listItems(collection)
+	if err != nil {
+		return nil, fmt.Errorf("TranspileEach failed: %s: %w", node.Position, err)
+	}
+
+	htmlNodes := make([]*HtmlNode, 0, len(items)*len(body))
+
+	for _, item := range items {
+		variables := make(map[string]any, 2)
+		if itemName != "" {
+			variables[itemName] = item.value
+		}
+		if indexName != "" {
+			variables[indexName] = item.index
+		}
+
+		childNodes, err := transpileSiblings(context.with(item.value, variables), body, parent, asElement)
+		if err != nil {
+			return nil, fmt.Errorf("TranspileEach failed: %w", err)
+		}
+		htmlNodes = append(htmlNodes, childNodes...)
+	}
+
+	return htmlNodes, nil
+}
+
+// Keys of conditional nodes, which are handled as a chain of siblings.
+var _CONDITION_KEYS = map[string]bool{
+	"if":      true,
+	"else-if": true,
+	"else":    true,
+}
+
+// Determines whether a value counts as true in a condition. False, zero, nil and empty strings,
+// slices and maps are false, like in text/template.
+func isTruthy(value any) bool {
+	reflected, ok := indirect(reflect.ValueOf(value))
+	if !ok || !reflected.IsValid() {
+		return false
+	}
+
+	switch reflected.Kind() {
+	case reflect.Slice, reflect.Array, reflect.Map, reflect.String, reflect.Chan:
+		return reflected.Len() > 0
+	case reflect.Struct:
+		return true
+	}
+
+	return !reflected.IsZero()
+}
+
+// Tracks a chain of if, else-if and else siblings while transpiling.
+type conditionChain struct {
+	// Whether the previous sibling was an if or else-if, so that an else-if or else can follow.
+	open bool
+	// Whether a condition in the chain has been met, so that the rest of it is skipped.
+	met bool
+}
+
+// Splits a conditional node into its test: and its body, which is everything else in it.
+// The test is nil for else.
+func (node *YamlNode) splitCondition() (*YamlNode, []*YamlNode, error) {
+	body := make([]*YamlNode, 0, len(node.Children))
+	var test *YamlNode
+
+	for _, child := range node.Children {
+		if child.Key == "test" {
+			test = child
+		} else {
+			body = append(body, child)
+		}
+	}
+
+	if node.Key == "else" && test != nil {
+		return nil, nil, fmt.Errorf("%s: else can't have a test, use else-if instead", test.Position)
+	}
+
+	if node.Key != "else" && test == nil {
+		return nil, nil, fmt.Errorf("%s: %s needs a test: with the condition", node.Position, node.Key)
+	}
+
+	return test, body, nil
+}
+
+// Evaluates the test: of a condition. It's either a single expression, or plain true or false.
+func (node *YamlNode) evaluateTest(context *transpileContext) (bool, error) {
+	if node.Type == RAW_YAML_NODE {
+		value, ok, err := context.evaluateSingle(node.Content)
+		if err != nil {
+			return false, fmt.Errorf("%s: %w", node.Position, err)
+		}
+		if ok {
+			return isTruthy(value), nil
+		}
+
+		if node.Plain && (isTrueScalar(node.Content) || isFalseScalar(node.Content)) {
+			return isTrueScalar(node.Content), nil
+		}
+	}
+
+	return false, fmt.Errorf("%s: test has to be a single expression, like ${ .isVisible }", node.Position)
+}
+
+// Transpiles the next sibling in a list. Conditional nodes are evaluated as part of the chain they are in,
+// and the body of the branch that is met is spliced into the parent. Any other node ends the chain.
+func (chain *conditionChain) transpile(context *transpileContext, node *YamlNode, parent *HtmlNode, asElement bool) ([]*HtmlNode, error) {
+	if node.Type != CHILDREN_YAML_NODE || !_CONDITION_KEYS[node.Key] {
+		*chain = conditionChain{}
+		return node.transpile(context, parent, asElement)
+	}
+
+	if node.Key != "if" && !chain.open {
+		return nil, fmt.Errorf("TranspileCondition failed: %s: %s has to follow an if or else-if", node.Position, node.Key)
+	}
+
+	if node.Key == "if" {
+		*chain = conditionChain{}
+	}
+	chain.open = node.Key != "else"
+
+	test, body, err := node.splitCondition()
+	if err != nil {
+		return nil, fmt.Errorf("TranspileCondition failed: %w", err)
+	}
+
+	// Once a branch is met, the rest of the chain is skipped without evaluating it.
+	if chain.met {
+		return []*HtmlNode{}, nil
+	}
+
+	if test != nil {
+		met, err := test.evaluateTest(context)
+		if err != nil {
+			return nil, fmt.Errorf("TranspileCondition failed: %w", err)
+		}
+
+		if !met {
+			return []*HtmlNode{}, nil
+		}
+	}
+
+	chain.met = true
+	return transpileSiblings(context, body, parent, asElement)
+}
+
+// Transpiles a list of sibling nodes, splicing loops and conditions into the result.
+func transpileSiblings(context *transpileContext, nodes []*YamlNode, parent *HtmlNode, asElement bool) ([]*HtmlNode, error) {
+	var chain conditionChain
+	htmlNodes := make([]*HtmlNode, 0, len(nodes))
+
+	for _, node := range nodes {
+		childNodes, err := chain.transpile(context, node, parent, asElement)
+		if err != nil {
+			return nil, err
+		}
+		htmlNodes = append(htmlNodes, childNodes...)
+	}
+
+	return htmlNodes, nil
+}
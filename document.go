@@ -0,0 +1,176 @@
+package yaml_tmpl
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Marks the start of a document in a stream.
+const _DOCUMENT_START = "---"
+
+// Marks the end of a document in a stream.
+const _DOCUMENT_END = "..."
+
+// The lines of a single document in a yaml stream.
+type documentLines struct {
+	// The line the document starts on. This is the --- marker if the document has one.
+	start sourceLine
+	lines []sourceLine
+}
+
+// Determines whether a line is the given document marker. Markers have to start at the first column.
+func isDocumentMarker(line string, marker string) bool {
+	if !strings.HasPrefix(line, marker) {
+		return false
+	}
+
+	rest := line[len(marker):]
+	return rest == "" || isWhitespace(rest[0])
+}
+
+// Makes sure nothing but a comment follows the document marker a line starts with.
+func checkDocumentMarker(state *parseState, line sourceLine, marker string) error {
+	rest := strings.TrimLeft(line.text[len(marker):], " \t")
+	if rest == "" || rest[0] == '#' {
+		return nil
+	}
+
+	column := len(line.text) - len(rest) + 1
+	return state.errorAt(line, column, "only a comment can follow %q on the same line", marker)
+}
+
+// Splits the lines of a yaml stream into documents on --- and ... markers.
+//
+// A document without content only counts if it is started explicitly with ---, so that a leading
+// --- or a trailing ... doesn't create an empty document.
+func splitDocuments(state *parseState, lines []sourceLine) ([]documentLines, error) {
+	documents := make([]documentLines, 0, 1)
+	var document documentLines
+	open := false
+
+	for _, line := range lines {
+		// Lines continuing a flow collection or belonging to a block scalar are never markers.
+		if line.continued || isBlank(line.text) {
+			document.lines = append(document.lines, line)
+			continue
+		}
+
+		switch {
+		case isDocumentMarker(line.text, _DOCUMENT_START):
+			if err := checkDocumentMarker(state, line, _DOCUMENT_START); err != nil {
+				return nil, fmt.Errorf("SplitDocuments failed: %w", err)
+			}
+			if open {
+				documents = append(documents, document)
+			}
+			document = documentLines{start: line}
+			open = true
+		case isDocumentMarker(line.text, _DOCUMENT_END):
+			if err := checkDocumentMarker(state, line, _DOCUMENT_END); err != nil {
+				return nil, fmt.Errorf("SplitDocuments failed: %w", err)
+			}
+			if open {
+				documents = append(documents, document)
+			}
+			document = documentLines{}
+			open = false
+		case !open && strings.HasPrefix(line.text, "%"):
+			// Directives such as %YAML 1.2 only apply to the yaml itself, so they are skipped.
+		default:
+			if !open {
+				document = documentLines{start: line}
+				open = true
+			}
+			document.lines = append(document.lines, line)
+		}
+	}
+
+	if open {
+		documents = append(documents, document)
+	}
+
+	return documents, nil
+}
+
+// Parses the lines of a single document into nodes. Anchors are local to the document.
+func parseDocument(file string, lines []sourceLine) ([]YamlNode, error) {
+	state := &parseState{
+		file:      file,
+		anchorMap: make(map[string]*YamlNode),
+	}
+
+	groups, err := collectGroups(state, lines)
+	if err != nil {
+		return nil, err
+	}
+
+	childNodes, err := parseEntries(state, groups, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	nodes := make([]YamlNode, 0, len(childNodes))
+	for _, childNode := range childNodes {
+		nodes = append(nodes, *childNode)
+	}
+	adoptChildren(nodes)
+
+	return nodes, nil
+}
+
+// Parses lines of a yaml stream into one list of nodes per document.
+//
+// Documents are separated by --- and can be ended with .... Errors in the source can be retrieved
+// as a *ParseError using errors.As.
+func GetYamlDocumentsFromLines(lines []string) ([][]YamlNode, error) {
+	documents, err := getYamlDocuments("", lines)
+	if err != nil {
+		return nil, fmt.Errorf("GetYamlDocumentsFromLines failed: %w", err)
+	}
+
+	return documents, nil
+}
+
+// Parses lines of a yaml stream from the named file into documents. The name is only used for positions.
+func getYamlDocuments(file string, lines []string) ([][]YamlNode, error) {
+	state := &parseState{file: file}
+
+	split, err := splitDocuments(state, getNonEmptyLines(lines))
+	if err != nil {
+		return nil, err
+	}
+
+	documents := make([][]YamlNode, 0, len(split))
+
+	for _, document := range split {
+		nodes, err := parseDocument(file, document.lines)
+		if err != nil {
+			return nil, err
+		}
+
+		documents = append(documents, nodes)
+	}
+
+	return documents, nil
+}
+
+// Parses lines of yaml from the named file into nodes. The name is only used for positions.
+// The source can start with --- and end with ..., but it can't hold more than one document.
+func getYamlNodes(file string, lines []string) ([]YamlNode, error) {
+	state := &parseState{file: file}
+
+	split, err := splitDocuments(state, getNonEmptyLines(lines))
+	if err != nil {
+		return nil, err
+	}
+
+	if len(split) == 0 {
+		return []YamlNode{}, nil
+	}
+
+	if len(split) > 1 {
+		return nil, state.errorAt(split[1].start, 1, "expected a single document, found %d", len(split))
+	}
+
+	return parseDocument(file, split[0].lines)
+}
@@ -0,0 +1,134 @@
+package yaml_tmpl
+
+import (
+	"fmt"
+	"io/fs"
+	"maps"
+	"reflect"
+)
+
+// Maps names to functions that expressions can call, like ${ .title | upper }.
+//
+// A function can take any number of arguments, and has to return a value, or a value and an error.
+// An error stops rendering. Piped values are passed as the last argument.
+type FuncMap map[string]any
+
+// Creates templates that share configuration, such as the functions they can call.
+type Engine struct {
+	functions FuncMap
+	// The file system that includes are resolved against in templates created with NewTemplate.
+	root fs.FS
+}
+
+// Creates an engine with the standard functions, such as upper, truncate and date.
+func NewEngine() *Engine {
+	return &Engine{functions: maps.Clone(_STANDARD_FUNCTIONS)}
+}
+
+// Determines whether a value can be called from an expression.
+func checkFunction(name string, function any) error {
+	if !isValidVariableName(name) || isKeyword(name) {
+		return fmt.Errorf("%q is not a valid function name", name)
+	}
+
+	functionType := reflect.TypeOf(function)
+	if functionType == nil || functionType.Kind() != reflect.Func {
+		return fmt.Errorf("%s is not a function", name)
+	}
+
+	if reflect.ValueOf(function).IsNil() {
+		return fmt.Errorf("function %s is nil", name)
+	}
+
+	returnsError := functionType.NumOut() == 2 && functionType.Out(1) == reflect.TypeFor[error]()
+	if functionType.NumOut() != 1 && !returnsError {
+		return fmt.Errorf("function %s has to return a value, and optionally an error", name)
+	}
+
+	return nil
+}
+
+// Adds functions that templates created afterwards can call. Functions with the name of an existing one,
+// including the standard ones, replace it. A zero Engine starts out without the standard functions.
+func (engine *Engine) Funcs(functions FuncMap) error {
+	for name, function := range functions {
+		err := checkFunction(name, function)
+		if err != nil {
+			return fmt.Errorf("Funcs failed: %w", err)
+		}
+	}
+
+	if engine.functions == nil {
+		engine.functions = make(FuncMap, len(functions))
+	}
+
+	maps.Copy(engine.functions, functions)
+	return nil
+}
+
+// Sets the file system that include: and layout: paths are resolved against in templates created with NewTemplate.
+// Templates created with ParseTemplateFS resolve them against the file system they are read from.
+func (engine *Engine) SetRoot(fsys fs.FS) {
+	engine.root = fsys
+}
+
+// Creates a template from nodes, using the loader to load the templates it includes or is laid out in, compiling
+// its expressions and collecting its components.
+func (engine *Engine) newTemplate(loader *templateLoader, nodes []YamlNode) (*Template, error) {
+	nodes, err := loader.loadTemplate(nodes)
+	if err != nil {
+		return nil, err
+	}
+
+	template := &Template{
+		functions: maps.Clone(engine.functions),
+		compiled:  make(map[string]*interpolation),
+	}
+
+	// Component declarations are compiled too, before they are taken out of the nodes.
+	for index := range nodes {
+		err := template.compile(&nodes[index])
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	template.components, template.nodes, err = collectComponents(nodes)
+	if err != nil {
+		return nil, err
+	}
+
+	return template, nil
+}
+
+// Creates a template from parsed yaml nodes, which can call the functions of the engine.
+// Includes and layouts are resolved against the root set with SetRoot.
+//
+// Expressions are compiled up front, so that a mistake in one is reported even if it's never evaluated.
+// Such errors can be retrieved as a *ParseError using errors.As.
+func (engine *Engine) NewTemplate(nodes []YamlNode) (*Template, error) {
+	template, err := engine.newTemplate(newTemplateLoader(engine.root, ""), nodes)
+	if err != nil {
+		return nil, fmt.Errorf("NewTemplate failed: %w", err)
+	}
+
+	return template, nil
+}
+
+// Parses the named yaml template in a file system. Includes and layouts are resolved relative to it, or
+// against the root of the file system if they start with a slash.
+func (engine *Engine) ParseTemplateFS(fsys fs.FS, name string) (*Template, error) {
+	loader := newTemplateLoader(fsys, name)
+
+	nodes, err := loader.read(name)
+	if err != nil {
+		return nil, fmt.Errorf("ParseTemplateFS failed: %w", err)
+	}
+
+	template, err := engine.newTemplate(loader, nodes)
+	if err != nil {
+		return nil, fmt.Errorf("ParseTemplateFS failed: %w", err)
+	}
+
+	return template, nil
+}
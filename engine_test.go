@@ -0,0 +1,220 @@
+package yaml_tmpl_test
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/frodi-karlsson/yaml_tmpl"
+)
+
+func renderWithEngine(t *testing.T, engine *yaml_tmpl.Engine, lines []string, data any) (string, error) {
+	t.Helper()
+
+	nodes, err := yaml_tmpl.GetYamlNodesFromLines(lines)
+	if err != nil {
+		return "", err
+	}
+
+	template, err := engine.NewTemplate(nodes)
+	if err != nil {
+		return "", err
+	}
+
+	var out strings.Builder
+	err = template.Render(&out, data)
+	return out.String(), err
+}
+
+func TestStandardFunctions(t *testing.T) {
+	tests := []struct {
+		expression string
+		expected   string
+	}{
+		{".name | upper", "ADA LOVELACE"},
+		{"upper(.name)", "ADA LOVELACE"},
+		{"lower .name", "ada lovelace"},
+		{"'the analytical engine' | title", "The Analytical Engine"},
+		{"'  padded  ' | trim", "padded"},
+		{".name | truncate 5", "Ada …"},
+		{".name | truncate 80", "Ada Lovelace"},
+		{".name | truncate 5.0", "Ada …"},
+		{".name | replace 'Ada' 'Augusta'", "Augusta Lovelace"},
+		{".name | contains 'Love'", "true"},
+		{".name | hasPrefix 'Ada'", "true"},
+		{".name | hasSuffix 'Ada'", "false"},
+		{".tags | join ', '", "math, poetry"},
+		{"len(split(' ', .name))", "2"},
+		{".missing | default 'none'", "none"},
+		{".count | pluralize 'note' 'notes'", "notes"},
+		{"1 | pluralize 'note' 'notes'", "note"},
+		{".born | date '2006-01-02'", "1815-12-10"},
+		{".tags | json", "[\"math\",\"poetry\"]"},
+		{"printf '%s has %d notes' .name .count", "Ada Lovelace has 7 notes"},
+		{".name | truncate 5 | upper", "ADA …"},
+	}
+
+	data := map[string]any{
+		"name":  "Ada Lovelace",
+		"tags":  []string{"math", "poetry"},
+		"count": 7,
+		"born":  time.Date(1815, time.December, 10, 0, 0, 0, 0, time.UTC),
+	}
+
+	for _, test := range tests {
+		html, err := renderLines(t, []string{"p: \"${ " + test.expression + " }\""}, data)
+		if err != nil {
+			t.Fatalf("Unexpected error for %s: %v", test.expression, err)
+		}
+
+		expected := "<p>" + test.expected + "</p>"
+		if html != expected {
+			t.Errorf("Expected %s for %s, got %s", expected, test.expression, html)
+		}
+	}
+}
+
+func TestEngineFuncs(t *testing.T) {
+	engine := yaml_tmpl.NewEngine()
+	err := engine.Funcs(yaml_tmpl.FuncMap{
+		"shout": func(text string) string {
+			return strings.ToUpper(text) + "!"
+		},
+		"upper": func(text string) string {
+			return "replaced"
+		},
+		"price": func(cents int) (string, error) {
+			if cents < 0 {
+				return "", errors.New("negative price")
+			}
+			return fmt.Sprintf("$%d.%02d", cents/100, cents%100), nil
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	html, err := renderWithEngine(t, engine, []string{
+		"p:",
+		"  title: ${ .name | shout }",
+		"  innerText: ${ upper(.name) }",
+	}, map[string]any{"name": "hi"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := "<p title=\"HI!\">replaced</p>"
+	if html != expected {
+		t.Errorf("Expected %s, got %s", expected, html)
+	}
+
+	_, err = renderWithEngine(t, engine, []string{"p: ${ price(-1) }"}, nil)
+	if err == nil || !strings.Contains(err.Error(), "function price failed: negative price") {
+		t.Errorf("Expected the error of the function, got %v", err)
+	}
+
+	// Templates created without the engine don't know its functions.
+	_, err = renderLines(t, []string{"p: ${ shout(.name) }"}, nil)
+	if err == nil || !strings.Contains(err.Error(), "function \"shout\" is not defined") {
+		t.Errorf("Expected an unknown function error, got %v", err)
+	}
+}
+
+func TestZeroEngineFuncs(t *testing.T) {
+	engine := &yaml_tmpl.Engine{}
+	err := engine.Funcs(yaml_tmpl.FuncMap{"shout": func(text string) string {
+		return strings.ToUpper(text) + "!"
+	}})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	html, err := renderWithEngine(t, engine, []string{"p: ${ shout(.name) }"}, map[string]any{"name": "hi"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := "<p>HI!</p>"
+	if html != expected {
+		t.Errorf("Expected %s, got %s", expected, html)
+	}
+}
+
+func TestEngineFuncsErrors(t *testing.T) {
+	tests := []struct {
+		functions yaml_tmpl.FuncMap
+		message   string
+	}{
+		{yaml_tmpl.FuncMap{"not-valid": strings.ToUpper}, "not a valid function name"},
+		{yaml_tmpl.FuncMap{"true": strings.ToUpper}, "not a valid function name"},
+		{yaml_tmpl.FuncMap{"value": "text"}, "value is not a function"},
+		{yaml_tmpl.FuncMap{"nothing": func() {}}, "has to return a value"},
+		{yaml_tmpl.FuncMap{"missing": (func() string)(nil)}, "function missing is nil"},
+	}
+
+	for _, test := range tests {
+		err := yaml_tmpl.NewEngine().Funcs(test.functions)
+		if err == nil || !strings.Contains(err.Error(), test.message) {
+			t.Errorf("Expected an error containing %q, got %v", test.message, err)
+		}
+	}
+}
+
+func TestFunctionArgumentErrors(t *testing.T) {
+	tests := []struct {
+		expression string
+		message    string
+	}{
+		{"upper(.count)", "argument 1 of upper has to be string, not int"},
+		{"truncate 5", "truncate takes 2 arguments, got 1"},
+		{"'text' | truncate 2.7", "argument 1 of truncate has to be int, not float64"},
+		{"small(300)", "argument 1 of small has to be int8, not int64"},
+		{"small(-1.5)", "argument 1 of small has to be int8, not float64"},
+		{".count | date '2006'", "can't format int as a date"},
+		{".count | join ','", "can't join int"},
+	}
+
+	engine := yaml_tmpl.NewEngine()
+	err := engine.Funcs(yaml_tmpl.FuncMap{"small": func(number int8) int8 { return number }})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, test := range tests {
+		_, err := renderWithEngine(t, engine, []string{"p: \"${ " + test.expression + " }\""}, map[string]any{"count": 1})
+		if err == nil || !strings.Contains(err.Error(), test.message) {
+			t.Errorf("Expected an error containing %q for %s, got %v", test.message, test.expression, err)
+		}
+	}
+}
+
+type explodingValue struct{}
+
+func (explodingValue) Explode() string {
+	panic("boom")
+}
+
+func TestFunctionPanics(t *testing.T) {
+	engine := yaml_tmpl.NewEngine()
+	err := engine.Funcs(yaml_tmpl.FuncMap{"explode": func() string { panic("boom") }})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		expression string
+		message    string
+	}{
+		{"explode()", "function explode panicked: boom"},
+		{".value.Explode", "method Explode panicked: boom"},
+	}
+
+	for _, test := range tests {
+		_, err := renderWithEngine(t, engine, []string{"p: \"${ " + test.expression + " }\""}, map[string]any{"value": explodingValue{}})
+		if err == nil || !strings.Contains(err.Error(), test.message) {
+			t.Errorf("Expected an error containing %q for %s, got %v", test.message, test.expression, err)
+		}
+	}
+}
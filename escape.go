@@ -0,0 +1,335 @@
+package yaml_tmpl
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+	"unicode/utf8"
+)
+
+// Attributes whose values are URLs. Their values are filtered for unsafe schemes and normalized
+// before being escaped as any other attribute value. A srcset is a list of URLs, each checked on its own.
+var _URL_ATTRIBUTES = map[string]bool{
+	"action":     true,
+	"background": true,
+	"cite":       true,
+	"formaction": true,
+	"href":       true,
+	"longdesc":   true,
+	"manifest":   true,
+	"poster":     true,
+	"src":        true,
+	"srcset":     true,
+	"usemap":     true,
+}
+
+// URL schemes that are allowed in URL attributes. Relative URLs have no scheme and are always allowed.
+var _SAFE_URL_SCHEMES = map[string]bool{
+	"http":   true,
+	"https":  true,
+	"mailto": true,
+	"tel":    true,
+}
+
+// Elements whose content is raw text, and can't contain character references.
+var _RAW_TEXT_ELEMENTS = map[string]bool{
+	"script": true,
+	"style":  true,
+}
+
+// The language of the text that the value of an expression is put into. Escaping HTML can't make a value
+// safe in a script or a stylesheet, so values put into those are escaped for their language instead.
+type valueLanguage int
+
+const (
+	_HTML_LANGUAGE valueLanguage = iota
+	_JS_LANGUAGE
+	_CSS_LANGUAGE
+)
+
+// Replaces URLs with a disallowed scheme.
+const _UNSAFE_URL = "about:invalid#unsafe-url"
+
+var textEscaper = strings.NewReplacer(
+	"&", "&amp;",
+	"<", "&lt;",
+	">", "&gt;",
+)
+
+var attributeEscaper = strings.NewReplacer(
+	"&", "&amp;",
+	"<", "&lt;",
+	">", "&gt;",
+	"\"", "&#34;",
+	"'", "&#39;",
+)
+
+// Escapes the content of a raw text element such as script or style. These can't contain
+// character references, so instead we make sure the content can't close the element early.
+func escapeRawText(tag string, content string) string {
+	closing := "</" + strings.ToLower(tag)
+	lower := strings.ToLower(content)
+
+	var builder strings.Builder
+	builder.Grow(len(content))
+
+	for index := 0; index < len(content); {
+		if strings.HasPrefix(lower[index:], closing) {
+			builder.WriteString("<\\/")
+			builder.WriteString(content[index+2 : index+len(closing)])
+			index += len(closing)
+		} else if strings.HasPrefix(lower[index:], "<!--") {
+			builder.WriteString("<\\!--")
+			index += 4
+		} else {
+			builder.WriteByte(content[index])
+			index++
+		}
+	}
+
+	return builder.String()
+}
+
+// Writes content escaped based on the element it's a child of.
+func writeEscapedContent(writer *htmlWriter, tag string, content string) {
+	if _RAW_TEXT_ELEMENTS[strings.ToLower(tag)] {
+		writer.writeString(escapeRawText(tag, content))
+		return
+	}
+
+	writer.writeEscaped(textEscaper, content)
+}
+
+// Returns an attribute value as it should be written, before escaping. Only URLs are changed.
+func attributeValue(name string, value string) string {
+	lower := strings.ToLower(name)
+	if lower == "srcset" {
+		return filterSrcset(value)
+	}
+
+	if _URL_ATTRIBUTES[lower] {
+		return normalizeURL(filterURL(value))
+	}
+
+	return value
+}
+
+// Writes an attribute value escaped so that it can be put between double quotes.
+func writeEscapedAttribute(writer *htmlWriter, name string, value string) {
+	writer.writeEscaped(attributeEscaper, attributeValue(name, value))
+}
+
+// Replaces the URL if it uses a scheme that is not known to be safe.
+func filterURL(url string) string {
+	trimmed := strings.TrimSpace(url)
+
+	for index, char := range trimmed {
+		if char == '/' || char == '?' || char == '#' {
+			// We reached the path before finding a scheme, so it's relative.
+			return url
+		}
+
+		if char == ':' {
+			scheme := strings.ToLower(trimmed[:index])
+			if _SAFE_URL_SCHEMES[scheme] {
+				return url
+			}
+			return _UNSAFE_URL
+		}
+	}
+
+	return url
+}
+
+// Filters and normalizes each URL of a srcset, which is a comma separated list of URLs that are
+// each followed by an optional descriptor, like "small.png 1x, large.png 2x".
+func filterSrcset(srcset string) string {
+	candidates := strings.Split(srcset, ",")
+
+	for index, candidate := range candidates {
+		fields := strings.Fields(candidate)
+		if len(fields) == 0 {
+			candidates[index] = ""
+			continue
+		}
+
+		fields[0] = normalizeURL(filterURL(fields[0]))
+		candidates[index] = strings.Join(fields, " ")
+	}
+
+	return strings.Join(candidates, ", ")
+}
+
+func isURLCharacter(char byte) bool {
+	if 'a' <= char && char <= 'z' || 'A' <= char && char <= 'Z' || '0' <= char && char <= '9' {
+		return true
+	}
+
+	return strings.IndexByte("-._~:/?#[]@!$&'()*+,;=%", char) != -1
+}
+
+// Percent-encodes any bytes that are not allowed in a URL. Existing percent-encodings are kept.
+func normalizeURL(url string) string {
+	const hex = "0123456789ABCDEF"
+
+	var builder strings.Builder
+	builder.Grow(len(url))
+
+	for index := 0; index < len(url); index++ {
+		char := url[index]
+		if isURLCharacter(char) {
+			builder.WriteByte(char)
+			continue
+		}
+
+		builder.WriteByte('%')
+		builder.WriteByte(hex[char>>4])
+		builder.WriteByte(hex[char&0x0f])
+	}
+
+	return builder.String()
+}
+
+// Determines the language of the content of a node, from its key and the tag of its parent.
+// Event handlers like onclick are JavaScript, style attributes are CSS, and so is the text of
+// script and style elements. Everything else, including raw: content, is HTML.
+func contentLanguage(key string, parent *HtmlNode, asElement bool) valueLanguage {
+	tag := strings.ToLower(key)
+
+	if key == "" || !asElement && key == "innerText" {
+		if parent == nil {
+			return _HTML_LANGUAGE
+		}
+		tag = strings.ToLower(parent.Tag)
+	} else if !asElement {
+		switch {
+		case strings.HasPrefix(tag, "on"):
+			return _JS_LANGUAGE
+		case tag == "style":
+			return _CSS_LANGUAGE
+		}
+		return _HTML_LANGUAGE
+	}
+
+	switch tag {
+	case "script":
+		return _JS_LANGUAGE
+	case "style":
+		return _CSS_LANGUAGE
+	}
+
+	return _HTML_LANGUAGE
+}
+
+// Returns the quote of the string literal that is open after text, given the one open before it,
+// or 0 if none is. Backslashes escape the character after them. HTML has no string literals.
+func (language valueLanguage) openQuote(quote byte, text string) byte {
+	quotes := ""
+	switch language {
+	case _JS_LANGUAGE:
+		quotes = "'\"`"
+	case _CSS_LANGUAGE:
+		quotes = "'\""
+	}
+
+	for index := 0; index < len(text); index++ {
+		char := text[index]
+
+		switch {
+		case quote != 0 && char == '\\':
+			index++
+		case quote != 0:
+			if char == quote {
+				quote = 0
+			}
+		case strings.IndexByte(quotes, char) != -1:
+			quote = char
+		}
+	}
+
+	return quote
+}
+
+// Formats the value of an expression for the language it's put into, inside a string literal
+// with the given quote, or outside of one if it's 0. HTML is escaped when it's written instead.
+func (language valueLanguage) format(value any, quote byte) (string, error) {
+	switch language {
+	case _JS_LANGUAGE:
+		if quote != 0 {
+			return escapeJSString(formatValue(value)), nil
+		}
+		return formatJSValue(value), nil
+	case _CSS_LANGUAGE:
+		if quote != 0 {
+			return escapeCSSString(formatValue(value)), nil
+		}
+		return filterCSSValue(formatValue(value))
+	}
+
+	return formatValue(value), nil
+}
+
+// Determines whether a character can be left as is in a JavaScript string.
+func isJSStringSafe(char rune) bool {
+	return isLetter(byte(char)) || isDigit(byte(char)) || strings.ContainsRune(" ,.-_:;!?()[]{}#@*~^|%=+", char)
+}
+
+// Escapes text for a JavaScript string literal with any quote. Quotes, backslashes, $ and anything
+// that could end the string, the script or the attribute around it are written as \u escapes.
+func escapeJSString(text string) string {
+	var builder strings.Builder
+	builder.Grow(len(text))
+
+	for _, char := range text {
+		if char < utf8.RuneSelf && !isJSStringSafe(char) || char == '\u2028' || char == '\u2029' {
+			fmt.Fprintf(&builder, "\\u%04x", char)
+			continue
+		}
+		builder.WriteRune(char)
+	}
+
+	return builder.String()
+}
+
+// Formats a value as a JavaScript literal, so that it's data rather than code. Values that can't
+// be encoded as JSON are written as a string. Spaces around the value keep it from joining the code
+// next to it, like a - before a negative number.
+func formatJSValue(value any) string {
+	encoded, err := json.Marshal(value)
+	if err != nil {
+		return " \"" + escapeJSString(formatValue(value)) + "\" "
+	}
+
+	// JSON is already escaped for HTML, with <, > and & written as \u escapes.
+	return " " + string(encoded) + " "
+}
+
+// Escapes text for a CSS string literal with any quote, writing anything but letters, digits and
+// spaces as a hex escape. The space after an escape ends it, and isn't part of the string.
+func escapeCSSString(text string) string {
+	var builder strings.Builder
+	builder.Grow(len(text))
+
+	for _, char := range text {
+		if char < utf8.RuneSelf && !isLetter(byte(char)) && !isDigit(byte(char)) && char != ' ' {
+			fmt.Fprintf(&builder, "\\%x ", char)
+			continue
+		}
+		builder.WriteRune(char)
+	}
+
+	return builder.String()
+}
+
+// Checks that a value put into CSS outside of a string can't do more than set a value, like a color
+// or a length. It can't end the declaration or the rule, or call a function like url().
+func filterCSSValue(text string) (string, error) {
+	for index := 0; index < len(text); index++ {
+		char := text[index]
+		if !isLetter(char) && !isDigit(char) && strings.IndexByte(" #.,%+-_!", char) == -1 && char < utf8.RuneSelf {
+			return "", fmt.Errorf("%q can't be put in CSS outside of a string", text)
+		}
+	}
+
+	return text, nil
+}
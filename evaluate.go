@@ -0,0 +1,564 @@
+package yaml_tmpl
+
+import (
+	"cmp"
+	"fmt"
+	"math"
+	"math/big"
+	"reflect"
+	"strconv"
+)
+
+// Formats the value of an expression as text. Nil is empty.
+func formatValue(value any) string {
+	switch value := value.(type) {
+	case nil:
+		return ""
+	case string:
+		return value
+	case bool:
+		return strconv.FormatBool(value)
+	case fmt.Stringer:
+		return value.String()
+	}
+
+	return fmt.Sprint(value)
+}
+
+// Dereferences pointers and interfaces. Returns false if a nil is reached.
+func indirect(value reflect.Value) (reflect.Value, bool) {
+	for value.Kind() == reflect.Pointer || value.Kind() == reflect.Interface {
+		if value.IsNil() {
+			return value, false
+		}
+		value = value.Elem()
+	}
+
+	return value, true
+}
+
+// Looks up a field, map key, method or index of a value by name.
+//
+// Missing map keys are nil, like in text/template, so that optional data can be left out.
+// Missing struct fields are an error, as they are most likely a typo.
+func lookupField(value any, name string) (any, error) {
+	receiver := reflect.ValueOf(value)
+	if !receiver.IsValid() {
+		return nil, fmt.Errorf("can't get %s of nil", name)
+	}
+
+	// Methods can be defined on the pointer, so look them up before dereferencing.
+	if method := receiver.MethodByName(name); method.IsValid() {
+		return callMethod(method, name)
+	}
+
+	receiver, ok := indirect(receiver)
+	if !ok {
+		return nil, fmt.Errorf("can't get %s of nil", name)
+	}
+
+	if method := receiver.MethodByName(name); method.IsValid() {
+		return callMethod(method, name)
+	}
+
+	switch receiver.Kind() {
+	case reflect.Struct:
+		field, found := receiver.Type().FieldByName(name)
+		if !found || !field.IsExported() {
+			return nil, fmt.Errorf("%s has no field %s", receiver.Type(), name)
+		}
+		return receiver.FieldByIndex(field.Index).Interface(), nil
+	case reflect.Map:
+		if receiver.Type().Key().Kind() != reflect.String {
+			return nil, fmt.Errorf("can't get %s of %s, as its keys are not strings", name, receiver.Type())
+		}
+		return mapIndex(receiver, reflect.ValueOf(name).Convert(receiver.Type().Key())), nil
+	case reflect.Slice, reflect.Array, reflect.String:
+		index, err := strconv.Atoi(name)
+		if err != nil {
+			return nil, fmt.Errorf("can't get %s of %s", name, receiver.Type())
+		}
+		return sequenceIndex(receiver, int64(index))
+	}
+
+	return nil, fmt.Errorf("can't get %s of %s", name, receiver.Type())
+}
+
+// Returns the value of a map for a key, or nil if there is none.
+func mapIndex(receiver reflect.Value, key reflect.Value) any {
+	element := receiver.MapIndex(key)
+	if !element.IsValid() {
+		return nil
+	}
+
+	return element.Interface()
+}
+
+// Returns the element of a slice, array or string at an index.
+func sequenceIndex(receiver reflect.Value, index int64) (any, error) {
+	if index < 0 || index >= int64(receiver.Len()) {
+		return nil, fmt.Errorf("index %d is out of range for length %d", index, receiver.Len())
+	}
+
+	return receiver.Index(int(index)).Interface(), nil
+}
+
+// Indexes a value, like .items[0] or .prices["apple"]. Missing map keys are nil, like with fields.
+func indexValue(value any, index any) (any, error) {
+	receiver, ok := indirect(reflect.ValueOf(value))
+	if !ok || !receiver.IsValid() {
+		return nil, fmt.Errorf("can't index nil")
+	}
+
+	switch receiver.Kind() {
+	case reflect.Map:
+		if index == nil {
+			return nil, fmt.Errorf("can't index %s with nil", receiver.Type())
+		}
+
+		key, ok := convertArgument(index, receiver.Type().Key())
+		if !ok {
+			return nil, fmt.Errorf("can't index %s with %T", receiver.Type(), index)
+		}
+		return mapIndex(receiver, key), nil
+	case reflect.Slice, reflect.Array, reflect.String:
+		number, ok := toNumber(index)
+		integer, isInteger := number.(int64)
+		if !ok || !isInteger {
+			return nil, fmt.Errorf("can't index %s with %T", receiver.Type(), index)
+		}
+		return sequenceIndex(receiver, integer)
+	}
+
+	return nil, fmt.Errorf("can't index %s", receiver.Type())
+}
+
+// Calls a function or method, turning a panic into an error, so that a broken function can't crash
+// the caller of Render. The description names what is called, like "function upper".
+func safeCall(function reflect.Value, arguments []reflect.Value, description string) (results []reflect.Value, err error) {
+	defer func() {
+		if recovered := recover(); recovered != nil {
+			err = fmt.Errorf("%s panicked: %v", description, recovered)
+		}
+	}()
+
+	return function.Call(arguments), nil
+}
+
+// Calls a method without arguments. It can return a value, or a value and an error.
+func callMethod(method reflect.Value, name string) (any, error) {
+	methodType := method.Type()
+	returnsError := methodType.NumOut() == 2 && methodType.Out(1) == reflect.TypeFor[error]()
+	if methodType.NumIn() != 0 || methodType.NumOut() != 1 && !returnsError {
+		return nil, fmt.Errorf("method %s has to take no arguments and return a value, and optionally an error", name)
+	}
+
+	results, err := safeCall(method, nil, "method "+name)
+	if err != nil {
+		return nil, err
+	}
+	if returnsError && !results[1].IsNil() {
+		return nil, fmt.Errorf("method %s failed: %w", name, results[1].Interface().(error))
+	}
+
+	return results[0].Interface(), nil
+}
+
+// Returns the exact value of a number.
+func exactNumber(value reflect.Value) *big.Float {
+	switch {
+	case value.CanInt():
+		return new(big.Float).SetInt64(value.Int())
+	case value.CanUint():
+		return new(big.Float).SetUint64(value.Uint())
+	}
+
+	return new(big.Float).SetFloat64(value.Float())
+}
+
+// Converts a number to another number type. Floats can be rounded to a smaller float type, but other
+// conversions have to keep the value as it is, so that 2.7 isn't truncated to 2 and 300 doesn't wrap
+// around in an int8.
+func convertNumber(value reflect.Value, numberType reflect.Type) (reflect.Value, bool) {
+	converted := value.Convert(numberType)
+
+	if value.CanFloat() && converted.CanFloat() {
+		return converted, !math.IsInf(converted.Float(), 0) || math.IsInf(value.Float(), 0)
+	}
+
+	if value.CanFloat() && math.IsNaN(value.Float()) {
+		return converted, false
+	}
+
+	return converted, exactNumber(value).Cmp(exactNumber(converted)) == 0
+}
+
+// Converts an argument to the type of a function parameter. Numbers are converted between
+// number types when their value fits, and nil is the zero value of types that can be nil.
+func convertArgument(argument any, parameterType reflect.Type) (reflect.Value, bool) {
+	value := reflect.ValueOf(argument)
+
+	if !value.IsValid() {
+		switch parameterType.Kind() {
+		case reflect.Interface, reflect.Pointer, reflect.Map, reflect.Slice, reflect.Func, reflect.Chan:
+			return reflect.Zero(parameterType), true
+		}
+		return value, false
+	}
+
+	switch {
+	case value.Type().AssignableTo(parameterType):
+		return value, true
+	case isNumberKind(value.Kind()) && isNumberKind(parameterType.Kind()):
+		return convertNumber(value, parameterType)
+	case value.Kind() == reflect.String && parameterType.Kind() == reflect.String:
+		return value.Convert(parameterType), true
+	}
+
+	return value, false
+}
+
+// Calls a function with the values of its arguments. It can return a value, or a value and an error.
+func callFunction(name string, function any, arguments []any) (any, error) {
+	reflected := reflect.ValueOf(function)
+	functionType := reflected.Type()
+
+	returnsError := functionType.NumOut() == 2 && functionType.Out(1) == reflect.TypeFor[error]()
+	if functionType.NumOut() != 1 && !returnsError {
+		return nil, fmt.Errorf("function %s has to return a value, and optionally an error", name)
+	}
+
+	parameters := functionType.NumIn()
+	if functionType.IsVariadic() && len(arguments) < parameters-1 {
+		return nil, fmt.Errorf("function %s takes at least %d arguments, got %d", name, parameters-1, len(arguments))
+	}
+	if !functionType.IsVariadic() && len(arguments) != parameters {
+		return nil, fmt.Errorf("function %s takes %d arguments, got %d", name, parameters, len(arguments))
+	}
+
+	values := make([]reflect.Value, len(arguments))
+	for index, argument := range arguments {
+		parameterType := functionType.In(min(index, parameters-1))
+		if functionType.IsVariadic() && index >= parameters-1 {
+			parameterType = parameterType.Elem()
+		}
+
+		value, ok := convertArgument(argument, parameterType)
+		if !ok {
+			return nil, fmt.Errorf("argument %d of %s has to be %s, not %T", index+1, name, parameterType, argument)
+		}
+		values[index] = value
+	}
+
+	results, err := safeCall(reflected, values, "function "+name)
+	if err != nil {
+		return nil, err
+	}
+	if returnsError && !results[1].IsNil() {
+		return nil, fmt.Errorf("function %s failed: %w", name, results[1].Interface().(error))
+	}
+
+	return results[0].Interface(), nil
+}
+
+// Determines whether a kind is an integer or a float.
+func isNumberKind(kind reflect.Kind) bool {
+	return reflect.Int <= kind && kind <= reflect.Float64 && kind != reflect.Uintptr
+}
+
+// Converts a number of any type to an int64 or a float64. Returns false if the value isn't a number.
+func toNumber(value any) (any, bool) {
+	reflected := reflect.ValueOf(value)
+
+	switch reflected.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return reflected.Int(), true
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		if reflected.Uint() > math.MaxInt64 {
+			return float64(reflected.Uint()), true
+		}
+		return int64(reflected.Uint()), true
+	case reflect.Float32, reflect.Float64:
+		return reflected.Float(), true
+	}
+
+	return nil, false
+}
+
+// Converts two numbers to the same type, which is float64 if either of them is a float.
+func toNumbers(left any, right any) (any, any, bool) {
+	left, leftOk := toNumber(left)
+	right, rightOk := toNumber(right)
+	if !leftOk || !rightOk {
+		return nil, nil, false
+	}
+
+	leftInteger, leftIsInteger := left.(int64)
+	rightInteger, rightIsInteger := right.(int64)
+	if leftIsInteger && rightIsInteger {
+		return leftInteger, rightInteger, true
+	}
+
+	if leftIsInteger {
+		left = float64(leftInteger)
+	}
+	if rightIsInteger {
+		right = float64(rightInteger)
+	}
+
+	return left, right, true
+}
+
+// Returns the value of a string of any string type. Returns false if the value isn't a string.
+func toString(value any) (string, bool) {
+	reflected := reflect.ValueOf(value)
+	if reflected.Kind() != reflect.String {
+		return "", false
+	}
+
+	return reflected.String(), true
+}
+
+// Determines whether a value is nil, including nil pointers, maps and slices.
+func isNil(value any) bool {
+	reflected := reflect.ValueOf(value)
+
+	switch reflected.Kind() {
+	case reflect.Invalid:
+		return true
+	case reflect.Pointer, reflect.Interface, reflect.Map, reflect.Slice, reflect.Func, reflect.Chan:
+		return reflected.IsNil()
+	}
+
+	return false
+}
+
+// Determines whether two values are equal. Numbers are compared by value regardless of their type,
+// and so are strings.
+func isEqual(left any, right any) bool {
+	if leftNumber, rightNumber, ok := toNumbers(left, right); ok {
+		return leftNumber == rightNumber
+	}
+
+	leftString, leftOk := toString(left)
+	rightString, rightOk := toString(right)
+	if leftOk && rightOk {
+		return leftString == rightString
+	}
+
+	if isNil(left) || isNil(right) {
+		return isNil(left) && isNil(right)
+	}
+
+	return reflect.DeepEqual(left, right)
+}
+
+// Compares two numbers or two strings, returning -1, 0 or 1.
+func compareValues(left any, right any) (int, error) {
+	if leftNumber, rightNumber, ok := toNumbers(left, right); ok {
+		switch leftNumber := leftNumber.(type) {
+		case int64:
+			return cmp.Compare(leftNumber, rightNumber.(int64)), nil
+		case float64:
+			return cmp.Compare(leftNumber, rightNumber.(float64)), nil
+		}
+	}
+
+	leftString, leftOk := toString(left)
+	rightString, rightOk := toString(right)
+	if leftOk && rightOk {
+		return cmp.Compare(leftString, rightString), nil
+	}
+
+	return 0, fmt.Errorf("can't compare %T and %T", left, right)
+}
+
+// Applies an arithmetic operator to two numbers. Integers stay integers, unless either of them is a float.
+func calculate(operator string, left any, right any) (any, error) {
+	leftNumber, rightNumber, ok := toNumbers(left, right)
+	if !ok {
+		return nil, fmt.Errorf("can't use %s on %T and %T", operator, left, right)
+	}
+
+	if leftInteger, isInteger := leftNumber.(int64); isInteger {
+		rightInteger := rightNumber.(int64)
+
+		switch operator {
+		case "+":
+			return leftInteger + rightInteger, nil
+		case "-":
+			return leftInteger - rightInteger, nil
+		case "*":
+			return leftInteger * rightInteger, nil
+		}
+
+		if rightInteger == 0 {
+			return nil, fmt.Errorf("division by zero")
+		}
+		if operator == "/" {
+			return leftInteger / rightInteger, nil
+		}
+		return leftInteger % rightInteger, nil
+	}
+
+	leftFloat, rightFloat := leftNumber.(float64), rightNumber.(float64)
+
+	switch operator {
+	case "+":
+		return leftFloat + rightFloat, nil
+	case "-":
+		return leftFloat - rightFloat, nil
+	case "*":
+		return leftFloat * rightFloat, nil
+	}
+
+	if rightFloat == 0 {
+		return nil, fmt.Errorf("division by zero")
+	}
+	if operator == "/" {
+		return leftFloat / rightFloat, nil
+	}
+	return math.Mod(leftFloat, rightFloat), nil
+}
+
+func (node *literalNode) evaluate(context *transpileContext) (any, error) {
+	return node.value, nil
+}
+
+func (node dotNode) evaluate(context *transpileContext) (any, error) {
+	return context.data, nil
+}
+
+func (node *variableNode) evaluate(context *transpileContext) (any, error) {
+	if node.name == "" {
+		return context.root, nil
+	}
+
+	value, exists := context.variables[node.name]
+	if !exists {
+		return nil, fmt.Errorf("variable $%s is not defined", node.name)
+	}
+
+	return value, nil
+}
+
+func (node *fieldNode) evaluate(context *transpileContext) (any, error) {
+	receiver := context.data
+	if node.receiver != nil {
+		var err error
+		receiver, err = node.receiver.evaluate(context)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return lookupField(receiver, node.name)
+}
+
+func (node *indexNode) evaluate(context *transpileContext) (any, error) {
+	receiver, err := node.receiver.evaluate(context)
+	if err != nil {
+		return nil, err
+	}
+
+	index, err := node.index.evaluate(context)
+	if err != nil {
+		return nil, err
+	}
+
+	return indexValue(receiver, index)
+}
+
+func (node *callNode) evaluate(context *transpileContext) (any, error) {
+	function, exists := context.functions[node.name]
+	if !exists {
+		return nil, fmt.Errorf("function %q is not defined", node.name)
+	}
+
+	arguments := make([]any, len(node.arguments))
+	for index, argument := range node.arguments {
+		value, err := argument.evaluate(context)
+		if err != nil {
+			return nil, err
+		}
+		arguments[index] = value
+	}
+
+	return callFunction(node.name, function, arguments)
+}
+
+func (node *unaryNode) evaluate(context *transpileContext) (any, error) {
+	operand, err := node.operand.evaluate(context)
+	if err != nil {
+		return nil, err
+	}
+
+	if node.operator == "!" {
+		return !isTruthy(operand), nil
+	}
+
+	switch number, _ := toNumber(operand); number := number.(type) {
+	case int64:
+		return -number, nil
+	case float64:
+		return -number, nil
+	}
+
+	return nil, fmt.Errorf("can't negate %T", operand)
+}
+
+func (node *binaryNode) evaluate(context *transpileContext) (any, error) {
+	left, err := node.left.evaluate(context)
+	if err != nil {
+		return nil, err
+	}
+
+	// && and || only evaluate their right side when it matters.
+	switch node.operator {
+	case "&&":
+		if !isTruthy(left) {
+			return false, nil
+		}
+	case "||":
+		if isTruthy(left) {
+			return true, nil
+		}
+	}
+
+	right, err := node.right.evaluate(context)
+	if err != nil {
+		return nil, err
+	}
+
+	switch node.operator {
+	case "&&", "||":
+		return isTruthy(right), nil
+	case "==":
+		return isEqual(left, right), nil
+	case "!=":
+		return !isEqual(left, right), nil
+	case "<", "<=", ">", ">=":
+		comparison, err := compareValues(left, right)
+		if err != nil {
+			return nil, err
+		}
+
+		switch node.operator {
+		case "<":
+			return comparison < 0, nil
+		case "<=":
+			return comparison <= 0, nil
+		case ">":
+			return comparison > 0, nil
+		}
+		return comparison >= 0, nil
+	case "+":
+		_, leftIsString := toString(left)
+		_, rightIsString := toString(right)
+		if leftIsString || rightIsString {
+			return formatValue(left) + formatValue(right), nil
+		}
+	}
+
+	return calculate(node.operator, left, right)
+}
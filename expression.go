@@ -0,0 +1,710 @@
+package yaml_tmpl
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// Starts an expression in a string, like ${ .name }.
+const _EXPRESSION_START = "${"
+
+// Written as ${ without starting an expression.
+const _ESCAPED_EXPRESSION_START = "$${"
+
+// Operators made of two characters. They are matched before single character operators.
+var _TWO_CHARACTER_OPERATORS = []string{"==", "!=", "<=", ">=", "&&", "||"}
+
+// Characters that are operators or punctuation on their own.
+const _SINGLE_CHARACTER_OPERATORS = "+-*/%<>!|()[],"
+
+// Binary operators by precedence, from the loosest to the tightest binding.
+var _BINARY_PRECEDENCE = [][]string{
+	{"||"},
+	{"&&"},
+	{"==", "!="},
+	{"<", "<=", ">", ">="},
+	{"+", "-"},
+	{"*", "/", "%"},
+}
+
+// Determines whether content contains an expression, or an escaped expression start that needs unescaping.
+func hasExpression(content string) bool {
+	return strings.Contains(content, _EXPRESSION_START)
+}
+
+// Finds the index of the } that ends an expression, skipping over string literals.
+// Returns -1 if the expression is unclosed.
+func findExpressionEnd(content string) int {
+	var quote byte
+
+	for index := 0; index < len(content); index++ {
+		char := content[index]
+
+		switch {
+		case quote != 0 && char == '\\':
+			index++
+		case quote != 0:
+			if char == quote {
+				quote = 0
+			}
+		case isQuote(char):
+			quote = char
+		case char == '}':
+			return index
+		}
+	}
+
+	return -1
+}
+
+type tokenKind int
+
+const (
+	_END_TOKEN tokenKind = iota
+	_NUMBER_TOKEN
+	_STRING_TOKEN
+	// A name, like upper or true.
+	_IDENTIFIER_TOKEN
+	// A field of the dot or of the value before it, like .name.
+	_FIELD_TOKEN
+	// The dot itself.
+	_DOT_TOKEN
+	// A variable like $item, or $ alone for the data the template is rendered with.
+	_VARIABLE_TOKEN
+	_OPERATOR_TOKEN
+)
+
+// A token of an expression.
+type token struct {
+	kind tokenKind
+	// The source of the token. For fields and variables, it's the name without the . or $.
+	// For strings, it's the unquoted value.
+	text string
+	// The 0-based offset of the token in the expression.
+	offset int
+	// Whether there is whitespace between this token and the one before it.
+	spaced bool
+}
+
+// An error in an expression, pointing at an offset in it.
+type expressionError struct {
+	offset  int
+	message string
+}
+
+func (err *expressionError) Error() string {
+	return fmt.Sprintf("%s at character %d", err.message, err.offset+1)
+}
+
+func isDigit(char byte) bool {
+	return '0' <= char && char <= '9'
+}
+
+func isNameCharacter(char byte) bool {
+	return isLetter(char) || isDigit(char) || char == '_'
+}
+
+// Determines whether a name can be used for a variable.
+func isValidVariableName(name string) bool {
+	if name == "" || !isLetter(name[0]) && name[0] != '_' {
+		return false
+	}
+
+	for index := 1; index < len(name); index++ {
+		if !isNameCharacter(name[index]) {
+			return false
+		}
+	}
+
+	return true
+}
+
+// Reads a quoted string starting at index, returning its value and the index after the closing quote.
+// Double quoted strings support the escapes of Go strings, single quoted strings have none.
+func lexString(source string, index int) (string, int, error) {
+	quote := source[index]
+
+	for end := index + 1; end < len(source); end++ {
+		if source[end] == '\\' && quote == '"' {
+			end++
+			continue
+		}
+
+		if source[end] != quote {
+			continue
+		}
+
+		if quote == '\'' {
+			return source[index+1 : end], end + 1, nil
+		}
+
+		value, err := strconv.Unquote(source[index : end+1])
+		if err != nil {
+			return "", 0, &expressionError{index, "invalid string " + source[index:end+1]}
+		}
+		return value, end + 1, nil
+	}
+
+	return "", 0, &expressionError{index, "missing closing quote"}
+}
+
+// Splits an expression into tokens.
+func lexExpression(source string) ([]token, error) {
+	tokens := make([]token, 0, 8)
+
+	for index := 0; index < len(source); {
+		start := index
+		for index < len(source) && isWhitespace(source[index]) {
+			index++
+		}
+		if index == len(source) {
+			break
+		}
+
+		next := token{offset: index, spaced: index > start}
+		char := source[index]
+
+		switch {
+		case isDigit(char):
+			end := index
+			for end < len(source) && isDigit(source[end]) {
+				end++
+			}
+			if end+1 < len(source) && source[end] == '.' && isDigit(source[end+1]) {
+				end++
+				for end < len(source) && isDigit(source[end]) {
+					end++
+				}
+			}
+			next.kind, next.text = _NUMBER_TOKEN, source[index:end]
+			index = end
+		case isQuote(char):
+			value, end, err := lexString(source, index)
+			if err != nil {
+				return nil, err
+			}
+			next.kind, next.text = _STRING_TOKEN, value
+			index = end
+		case char == '.' || char == '$':
+			end := index + 1
+			for end < len(source) && isNameCharacter(source[end]) {
+				end++
+			}
+			next.text = source[index+1 : end]
+			switch {
+			case char == '$':
+				next.kind = _VARIABLE_TOKEN
+			case next.text == "":
+				next.kind = _DOT_TOKEN
+			default:
+				next.kind = _FIELD_TOKEN
+			}
+			index = end
+		case isLetter(char) || char == '_':
+			end := index
+			for end < len(source) && isNameCharacter(source[end]) {
+				end++
+			}
+			next.kind, next.text = _IDENTIFIER_TOKEN, source[index:end]
+			index = end
+		default:
+			next.kind = _OPERATOR_TOKEN
+			for _, operator := range _TWO_CHARACTER_OPERATORS {
+				if strings.HasPrefix(source[index:], operator) {
+					next.text = operator
+				}
+			}
+			if next.text == "" && strings.IndexByte(_SINGLE_CHARACTER_OPERATORS, char) != -1 {
+				next.text = source[index : index+1]
+			}
+			if next.text == "" {
+				return nil, &expressionError{index, fmt.Sprintf("unexpected %q", char)}
+			}
+			index += len(next.text)
+		}
+
+		tokens = append(tokens, next)
+	}
+
+	return append(tokens, token{kind: _END_TOKEN, offset: len(source)}), nil
+}
+
+// A node of a parsed expression.
+type expressionNode interface {
+	evaluate(context *transpileContext) (any, error)
+}
+
+// A number, string, boolean or nil.
+type literalNode struct {
+	value any
+}
+
+// The dot, which is the data or the current item of a loop.
+type dotNode struct{}
+
+// A variable, or the data the template is rendered with if the name is empty.
+type variableNode struct {
+	name string
+}
+
+// A field of a value, like .name or $item.name. A nil receiver is the dot.
+type fieldNode struct {
+	receiver expressionNode
+	name     string
+}
+
+// An index into a value, like .items[0] or .prices["apple"].
+type indexNode struct {
+	receiver expressionNode
+	index    expressionNode
+}
+
+// A call to a function, like upper(.name). Piped values are the last argument.
+type callNode struct {
+	name      string
+	arguments []expressionNode
+}
+
+type unaryNode struct {
+	operator string
+	operand  expressionNode
+}
+
+type binaryNode struct {
+	operator string
+	left     expressionNode
+	right    expressionNode
+}
+
+// Parses the tokens of an expression into a tree.
+type expressionParser struct {
+	tokens []token
+	index  int
+	// Functions that can be called, used to report unknown functions when compiling.
+	functions FuncMap
+}
+
+func (parser *expressionParser) peek() token {
+	return parser.tokens[parser.index]
+}
+
+func (parser *expressionParser) next() token {
+	next := parser.tokens[parser.index]
+	if next.kind != _END_TOKEN {
+		parser.index++
+	}
+
+	return next
+}
+
+// Determines whether the next token is the given operator.
+func (parser *expressionParser) at(operator string) bool {
+	next := parser.peek()
+	return next.kind == _OPERATOR_TOKEN && next.text == operator
+}
+
+func (parser *expressionParser) unexpected(next token) error {
+	if next.kind == _END_TOKEN {
+		return &expressionError{next.offset, "unexpected end of expression"}
+	}
+
+	return &expressionError{next.offset, fmt.Sprintf("unexpected %q", describeToken(next))}
+}
+
+// Returns the source of a token, for use in errors.
+func describeToken(next token) string {
+	switch next.kind {
+	case _FIELD_TOKEN, _DOT_TOKEN:
+		return "." + next.text
+	case _VARIABLE_TOKEN:
+		return "$" + next.text
+	case _STRING_TOKEN:
+		return strconv.Quote(next.text)
+	}
+
+	return next.text
+}
+
+func (parser *expressionParser) expect(operator string) error {
+	if !parser.at(operator) {
+		return &expressionError{parser.peek().offset, fmt.Sprintf("expected %q", operator)}
+	}
+
+	parser.next()
+	return nil
+}
+
+// Parses a pipeline, like .name | upper | truncate(10). The value before a pipe is passed
+// to the function after it as the last argument.
+func (parser *expressionParser) parsePipeline() (expressionNode, error) {
+	node, err := parser.parseBinary(0)
+	if err != nil {
+		return nil, err
+	}
+
+	for parser.at("|") {
+		parser.next()
+
+		next := parser.peek()
+		if next.kind != _IDENTIFIER_TOKEN || isKeyword(next.text) {
+			return nil, &expressionError{next.offset, "expected a function after |"}
+		}
+
+		call, err := parser.parseCall(parser.next())
+		if err != nil {
+			return nil, err
+		}
+
+		call.arguments = append(call.arguments, node)
+		node = call
+	}
+
+	return node, nil
+}
+
+// Parses binary operators of the given precedence level and tighter.
+func (parser *expressionParser) parseBinary(level int) (expressionNode, error) {
+	if level == len(_BINARY_PRECEDENCE) {
+		return parser.parseUnary()
+	}
+
+	left, err := parser.parseBinary(level + 1)
+	if err != nil {
+		return nil, err
+	}
+
+	for {
+		next := parser.peek()
+		if next.kind != _OPERATOR_TOKEN || !containsOperator(_BINARY_PRECEDENCE[level], next.text) {
+			return left, nil
+		}
+		parser.next()
+
+		right, err := parser.parseBinary(level + 1)
+		if err != nil {
+			return nil, err
+		}
+
+		left = &binaryNode{operator: next.text, left: left, right: right}
+	}
+}
+
+func containsOperator(operators []string, operator string) bool {
+	for _, candidate := range operators {
+		if candidate == operator {
+			return true
+		}
+	}
+
+	return false
+}
+
+func (parser *expressionParser) parseUnary() (expressionNode, error) {
+	if parser.at("!") || parser.at("-") {
+		operator := parser.next()
+
+		operand, err := parser.parseUnary()
+		if err != nil {
+			return nil, err
+		}
+
+		return &unaryNode{operator: operator.text, operand: operand}, nil
+	}
+
+	return parser.parsePostfix()
+}
+
+// Parses a value followed by any number of fields and indices, like $item.tags[0].
+func (parser *expressionParser) parsePostfix() (expressionNode, error) {
+	node, err := parser.parsePrimary()
+	if err != nil {
+		return nil, err
+	}
+
+	for {
+		next := parser.peek()
+
+		switch {
+		case next.kind == _FIELD_TOKEN && !next.spaced:
+			parser.next()
+			node = &fieldNode{receiver: node, name: next.text}
+		case parser.at("[") && !next.spaced:
+			parser.next()
+
+			index, err := parser.parsePipeline()
+			if err != nil {
+				return nil, err
+			}
+
+			if err := parser.expect("]"); err != nil {
+				return nil, err
+			}
+
+			node = &indexNode{receiver: node, index: index}
+		default:
+			return node, nil
+		}
+	}
+}
+
+// Determines whether a name is a literal rather than a function.
+func isKeyword(name string) bool {
+	return name == "true" || name == "false" || name == "nil"
+}
+
+// Determines whether the next token starts an argument separated from a function name by a space,
+// like the 80 in truncate 80.
+func (parser *expressionParser) atSpacedArgument() bool {
+	next := parser.peek()
+	if !next.spaced {
+		return false
+	}
+
+	switch next.kind {
+	case _NUMBER_TOKEN, _STRING_TOKEN, _FIELD_TOKEN, _DOT_TOKEN, _VARIABLE_TOKEN:
+		return true
+	case _IDENTIFIER_TOKEN:
+		return isKeyword(next.text)
+	}
+
+	return parser.at("(")
+}
+
+// Parses a function call. Arguments are either in parentheses, like truncate(80, .text), or separated by spaces,
+// like truncate 80 .text. Arguments separated by spaces bind tighter than operators, and can't be calls
+// themselves unless they are in parentheses. Without arguments, upper and upper() are the same.
+func (parser *expressionParser) parseCall(name token) (*callNode, error) {
+	if _, exists := parser.functions[name.text]; !exists {
+		return nil, &expressionError{name.offset, fmt.Sprintf("function %q is not defined", name.text)}
+	}
+
+	call := &callNode{name: name.text, arguments: []expressionNode{}}
+
+	if !parser.at("(") || parser.peek().spaced {
+		for parser.atSpacedArgument() {
+			argument, err := parser.parsePostfix()
+			if err != nil {
+				return nil, err
+			}
+			call.arguments = append(call.arguments, argument)
+		}
+
+		return call, nil
+	}
+	parser.next()
+
+	for !parser.at(")") {
+		argument, err := parser.parsePipeline()
+		if err != nil {
+			return nil, err
+		}
+		call.arguments = append(call.arguments, argument)
+
+		if !parser.at(",") {
+			break
+		}
+		parser.next()
+	}
+
+	if err := parser.expect(")"); err != nil {
+		return nil, err
+	}
+
+	return call, nil
+}
+
+func (parser *expressionParser) parsePrimary() (expressionNode, error) {
+	next := parser.next()
+
+	switch next.kind {
+	case _NUMBER_TOKEN:
+		if strings.Contains(next.text, ".") {
+			value, err := strconv.ParseFloat(next.text, 64)
+			if err != nil {
+				return nil, &expressionError{next.offset, "invalid number " + next.text}
+			}
+			return &literalNode{value}, nil
+		}
+
+		value, err := strconv.ParseInt(next.text, 10, 64)
+		if err != nil {
+			return nil, &expressionError{next.offset, "invalid number " + next.text}
+		}
+		return &literalNode{value}, nil
+	case _STRING_TOKEN:
+		return &literalNode{next.text}, nil
+	case _DOT_TOKEN:
+		return dotNode{}, nil
+	case _FIELD_TOKEN:
+		return &fieldNode{name: next.text}, nil
+	case _VARIABLE_TOKEN:
+		return &variableNode{name: next.text}, nil
+	case _IDENTIFIER_TOKEN:
+		switch next.text {
+		case "true":
+			return &literalNode{true}, nil
+		case "false":
+			return &literalNode{false}, nil
+		case "nil":
+			return &literalNode{nil}, nil
+		}
+		return parser.parseCall(next)
+	case _OPERATOR_TOKEN:
+		if next.text == "(" {
+			node, err := parser.parsePipeline()
+			if err != nil {
+				return nil, err
+			}
+
+			if err := parser.expect(")"); err != nil {
+				return nil, err
+			}
+			return node, nil
+		}
+	}
+
+	return nil, parser.unexpected(next)
+}
+
+// Parses an expression, the part between ${ and }.
+func parseExpression(source string, functions FuncMap) (expressionNode, error) {
+	tokens, err := lexExpression(source)
+	if err != nil {
+		return nil, err
+	}
+
+	parser := &expressionParser{tokens: tokens, functions: functions}
+
+	if parser.peek().kind == _END_TOKEN {
+		return nil, &expressionError{0, "empty expression"}
+	}
+
+	node, err := parser.parsePipeline()
+	if err != nil {
+		return nil, err
+	}
+
+	if next := parser.peek(); next.kind != _END_TOKEN {
+		return nil, parser.unexpected(next)
+	}
+
+	return node, nil
+}
+
+// A part of a string with expressions in it. Either text or an expression.
+type interpolationSegment struct {
+	text       string
+	expression expressionNode
+}
+
+// A string with expressions in it, like "Hello ${ .name }", compiled for evaluation.
+type interpolation struct {
+	segments []interpolationSegment
+	// The expression, if the string is nothing but a single expression and whitespace.
+	single expressionNode
+}
+
+// Compiles a string with expressions in it. Errors are reported as an *expressionError, whose offset is
+// in the string.
+func compileInterpolation(content string, functions FuncMap) (*interpolation, error) {
+	compiled := &interpolation{}
+	text := ""
+	rest := content
+
+	for {
+		start := strings.Index(rest, _EXPRESSION_START)
+		if start == -1 {
+			text += rest
+			break
+		}
+
+		if start > 0 && rest[start-1] == '$' {
+			// $${ is an escaped ${.
+			text += rest[:start] + "{"
+			rest = rest[start+len(_EXPRESSION_START):]
+			continue
+		}
+
+		text += rest[:start]
+		if text != "" {
+			compiled.segments = append(compiled.segments, interpolationSegment{text: text})
+			text = ""
+		}
+
+		offset := len(content) - len(rest) + start + len(_EXPRESSION_START)
+		rest = rest[start+len(_EXPRESSION_START):]
+
+		end := findExpressionEnd(rest)
+		if end == -1 {
+			return nil, &expressionError{offset - len(_EXPRESSION_START), "expression is missing a closing }"}
+		}
+
+		expression, err := parseExpression(rest[:end], functions)
+		if err != nil {
+			var expressionErr *expressionError
+			if !errors.As(err, &expressionErr) {
+				return nil, fmt.Errorf("invalid expression ${%s}: %w", rest[:end], err)
+			}
+
+			// Offsets in the expression are moved to be in the string.
+			return nil, &expressionError{offset + expressionErr.offset, fmt.Sprintf("invalid expression ${%s}: %s", rest[:end], expressionErr.message)}
+		}
+
+		compiled.segments = append(compiled.segments, interpolationSegment{expression: expression})
+		rest = rest[end+1:]
+	}
+
+	if text != "" {
+		compiled.segments = append(compiled.segments, interpolationSegment{text: text})
+	}
+
+	expressions := 0
+	for _, segment := range compiled.segments {
+		if segment.expression != nil {
+			expressions++
+		} else if strings.TrimSpace(segment.text) != "" {
+			expressions = -1
+			break
+		}
+	}
+
+	if expressions == 1 {
+		for _, segment := range compiled.segments {
+			if segment.expression != nil {
+				compiled.single = segment.expression
+			}
+		}
+	}
+
+	return compiled, nil
+}
+
+// Evaluates the expressions of an interpolation, and joins them with the text around them.
+// Values are formatted for the language of the text, and for whether they are inside a string in it.
+func (compiled *interpolation) render(context *transpileContext, language valueLanguage) (string, error) {
+	var builder strings.Builder
+	var quote byte
+
+	for _, segment := range compiled.segments {
+		if segment.expression == nil {
+			builder.WriteString(segment.text)
+			quote = language.openQuote(quote, segment.text)
+			continue
+		}
+
+		value, err := segment.expression.evaluate(context)
+		if err != nil {
+			return "", err
+		}
+
+		formatted, err := language.format(value, quote)
+		if err != nil {
+			return "", err
+		}
+		builder.WriteString(formatted)
+	}
+
+	return builder.String(), nil
+}
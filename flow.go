@@ -0,0 +1,623 @@
+package yaml_tmpl
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Whether a character starts a flow collection.
+func isFlowStart(char byte) bool {
+	return char == '[' || char == '{'
+}
+
+// Whether a character has a special meaning inside of flow collections.
+func isFlowIndicator(char byte) bool {
+	return char == ',' || char == '[' || char == ']' || char == '{' || char == '}'
+}
+
+// Returns how deeply nested in flow collections we are after a line, given the depth before it.
+// Brackets in quotes and comments are ignored.
+func getFlowDepth(line string, depth int) int {
+	for index := 0; index < len(line); index++ {
+		char := line[index]
+
+		switch {
+		case isQuote(char):
+			end := findClosingQuote(line, index)
+			if end == -1 {
+				return depth
+			}
+			index = end
+		case char == '#' && (index == 0 || isWhitespace(line[index-1])):
+			return depth
+		case isFlowStart(char):
+			depth++
+		case char == ']' || char == '}':
+			depth--
+		}
+	}
+
+	return depth
+}
+
+// Reads a flow collection, which may span several lines.
+type flowParser struct {
+	state *parseState
+	lines []sourceLine
+	// The current line, as an index into lines.
+	line int
+	// The current byte in the current line.
+	index int
+}
+
+func (parser *flowParser) atEnd() bool {
+	return parser.line >= len(parser.lines)
+}
+
+func (parser *flowParser) atLineEnd() bool {
+	return parser.atEnd() || parser.index >= len(parser.lines[parser.line].text)
+}
+
+// Returns the current character, '\n' at the end of a line, or 0 at the end of the collection's lines.
+func (parser *flowParser) peek() byte {
+	if parser.atEnd() {
+		return 0
+	}
+
+	text := parser.lines[parser.line].text
+	if parser.index >= len(text) {
+		return '\n'
+	}
+
+	return text[parser.index]
+}
+
+// Returns the character after the current one on the same line, or '\n' if there is none.
+func (parser *flowParser) peekNext() byte {
+	if parser.atEnd() || parser.index+1 >= len(parser.lines[parser.line].text) {
+		return '\n'
+	}
+
+	return parser.lines[parser.line].text[parser.index+1]
+}
+
+func (parser *flowParser) advance() {
+	if parser.atLineEnd() {
+		parser.line++
+		parser.index = 0
+		return
+	}
+
+	parser.index++
+}
+
+// Skips whitespace, line breaks and comments.
+func (parser *flowParser) skipWhitespace() {
+	for !parser.atEnd() {
+		char := parser.peek()
+
+		if char == '#' && (parser.index == 0 || isWhitespace(parser.lines[parser.line].text[parser.index-1])) {
+			// The rest of the line is a comment.
+			parser.line++
+			parser.index = 0
+			continue
+		}
+
+		if char != ' ' && char != '\t' && char != '\r' && char != '\n' {
+			return
+		}
+
+		parser.advance()
+	}
+}
+
+// Returns the current line and 1-based column.
+func (parser *flowParser) location() (sourceLine, int) {
+	if parser.atEnd() {
+		last := parser.lines[len(parser.lines)-1]
+		return last, len(last.text) + 1
+	}
+
+	return parser.lines[parser.line], parser.index + 1
+}
+
+// Creates a ParseError at the current location.
+func (parser *flowParser) errorHere(format string, args ...any) error {
+	line, column := parser.location()
+	return parser.state.errorAt(line, column, format, args...)
+}
+
+// Returns the position of a node starting at the given location and ending at the current one.
+func (parser *flowParser) positionFrom(line sourceLine, column int) Position {
+	endLine := line.number
+	if parser.atEnd() {
+		endLine = parser.lines[len(parser.lines)-1].number
+	} else if parser.line < len(parser.lines) {
+		endLine = parser.lines[parser.line].number
+	}
+
+	return Position{
+		File:    parser.state.file,
+		Line:    line.number,
+		Column:  column,
+		EndLine: endLine,
+	}
+}
+
+// Reads a name following & or *, such as an anchor name.
+func (parser *flowParser) parseName() string {
+	start := parser.index
+	text := parser.lines[parser.line].text
+
+	for parser.index < len(text) && !isSpecial(text[parser.index]) {
+		parser.index++
+	}
+
+	return text[start:parser.index]
+}
+
+// Reads an anchor like &name if there is one, returning an empty string otherwise.
+func (parser *flowParser) parseAnchor() string {
+	if parser.peek() != '&' {
+		return ""
+	}
+
+	parser.advance()
+	name := parser.parseName()
+	parser.skipWhitespace()
+
+	return name
+}
+
+// Whether the current character is a colon separating a key from its value.
+func (parser *flowParser) atMappingColon() bool {
+	if parser.peek() != ':' {
+		return false
+	}
+
+	next := parser.peekNext()
+	return next == ' ' || next == '\t' || next == '\n' || isFlowIndicator(next)
+}
+
+// Reads a quoted scalar on the current line.
+func (parser *flowParser) parseQuotedScalar() (string, error) {
+	line, column := parser.location()
+
+	end := findClosingQuote(line.text, parser.index)
+	if end == -1 {
+		return "", parser.state.errorAt(line, column, "missing closing quote")
+	}
+
+	content := line.text[parser.index+1 : end]
+	quote := line.text[parser.index]
+	parser.index = end + 1
+
+	if quote == '\'' {
+		return strings.ReplaceAll(content, "''", "'"), nil
+	}
+
+	value, err := unescapeDoubleQuoted(content)
+	if err != nil {
+		return "", parser.state.errorAt(line, column, "%s", err.Error())
+	}
+
+	return value, nil
+}
+
+// Reads a plain scalar, which ends at a flow indicator, a mapping colon or a comment.
+// Line breaks inside of it are folded into spaces.
+func (parser *flowParser) parsePlainScalar() (string, error) {
+	char := parser.peek()
+	if strings.IndexByte(_RESERVED_INDICATORS, char) != -1 || char == '|' || char == '>' {
+		return "", parser.errorHere("a plain value can't start with %q, quote the value instead", char)
+	}
+
+	var builder strings.Builder
+
+	for !parser.atEnd() {
+		char := parser.peek()
+
+		if char == '\n' {
+			// Continue the scalar on the next line, unless the next line ends it.
+			line, index := parser.line, parser.index
+			parser.skipWhitespace()
+
+			next := parser.peek()
+			if parser.atEnd() || isFlowIndicator(next) || parser.atMappingColon() || next == '#' {
+				parser.line, parser.index = line, index
+				break
+			}
+
+			builder.WriteByte(' ')
+			continue
+		}
+
+		// Expressions like ${ .name } contain braces, so they are read as a whole.
+		if char == '$' && parser.peekNext() == '{' {
+			text := parser.lines[parser.line].text
+			end := findExpressionEnd(text[parser.index:])
+			if end == -1 {
+				return "", parser.errorHere("expression is missing a closing }")
+			}
+
+			builder.WriteString(text[parser.index : parser.index+end+1])
+			parser.index += end + 1
+			continue
+		}
+
+		if isFlowIndicator(char) || parser.atMappingColon() {
+			break
+		}
+
+		if char == '#' && parser.index > 0 && isWhitespace(parser.lines[parser.line].text[parser.index-1]) {
+			break
+		}
+
+		builder.WriteByte(char)
+		parser.advance()
+	}
+
+	return strings.TrimRight(builder.String(), " \t\r"), nil
+}
+
+// Reads a quoted or plain scalar.
+func (parser *flowParser) parseScalar() (string, error) {
+	if isQuote(parser.peek()) {
+		return parser.parseQuotedScalar()
+	}
+
+	return parser.parsePlainScalar()
+}
+
+// Reads an alias like *name and returns the anchor it refers to.
+func (parser *flowParser) parseAlias() (*YamlNode, error) {
+	line, column := parser.location()
+
+	parser.advance()
+	name := parser.parseName()
+
+	anchor, exists := parser.state.anchorMap[name]
+	if !exists {
+		return nil, parser.state.errorAt(line, column, "anchor %q is not defined", name)
+	}
+
+	return anchor, nil
+}
+
+// Whether the current entry has the merge key <<, like `<<: *anchor`.
+func (parser *flowParser) atMergeKey() bool {
+	if parser.atEnd() {
+		return false
+	}
+
+	text := parser.lines[parser.line].text[parser.index:]
+	if !strings.HasPrefix(text, "<<") {
+		return false
+	}
+
+	return strings.HasPrefix(strings.TrimLeft(text[2:], " \t"), ":")
+}
+
+// Reads the value of a merge key, which is an alias or a sequence of aliases like [*a, *b], and returns
+// copies of the children of the anchors. Keys of earlier anchors win over those of later ones.
+func (parser *flowParser) parseMerge(parent *YamlNode) ([]*YamlNode, error) {
+	if parser.peek() == '*' {
+		line, column := parser.location()
+		anchor, err := parser.parseAlias()
+		if err != nil {
+			return nil, err
+		}
+
+		return mergeAnchor(parser.state, line, column, anchor, parent)
+	}
+
+	line, column := parser.location()
+	parser.advance()
+	mapping := &mergedMapping{}
+
+	for {
+		parser.skipWhitespace()
+
+		if parser.atEnd() {
+			return nil, parser.state.errorAt(line, column, "missing closing %q", ']')
+		}
+
+		if parser.peek() == ']' {
+			parser.advance()
+			return mapping.resolve(), nil
+		}
+
+		if parser.peek() != '*' {
+			return nil, parser.errorHere("a merge key can only merge aliases, like <<: [*a, *b]")
+		}
+
+		merged, err := parser.parseMerge(parent)
+		if err != nil {
+			return nil, err
+		}
+		mapping.add(merged, _MERGED_ENTRY)
+
+		parser.skipWhitespace()
+
+		switch parser.peek() {
+		case ',':
+			parser.advance()
+		case ']':
+		default:
+			if parser.atEnd() {
+				return nil, parser.state.errorAt(line, column, "missing closing %q", ']')
+			}
+			return nil, parser.errorHere("expected \",\" or %q", ']')
+		}
+	}
+}
+
+// Registers an anchored node. Just like in block collections, anchored nodes are only
+// definitions, so they are not returned.
+func (parser *flowParser) registerAnchor(anchorName string, node *YamlNode) []*YamlNode {
+	if anchorName == "" {
+		return []*YamlNode{node}
+	}
+
+	node.AnchorName = anchorName
+	parser.state.anchorMap[anchorName] = node
+	return []*YamlNode{}
+}
+
+// Reads a flow sequence or mapping, returning the nodes inside of it.
+func (parser *flowParser) parseCollection(node *YamlNode) ([]*YamlNode, error) {
+	line, column := parser.location()
+
+	opening := parser.peek()
+	closing := byte(']')
+	if opening == '{' {
+		closing = '}'
+	}
+	isSequence := opening == '['
+
+	parser.advance()
+	mapping := &mergedMapping{}
+
+	for {
+		parser.skipWhitespace()
+
+		if parser.atEnd() {
+			return nil, parser.state.errorAt(line, column, "missing closing %q", closing)
+		}
+
+		if parser.peek() == closing {
+			parser.advance()
+			return mapping.resolve(), nil
+		}
+
+		role := _LOCAL_ENTRY
+		if isSequence {
+			role = _SEQUENCE_ENTRY
+		} else if parser.atMergeKey() {
+			role = _MERGED_ENTRY
+		}
+
+		entries, err := parser.parseEntry(node, isSequence)
+		if err != nil {
+			return nil, err
+		}
+		mapping.add(entries, role)
+
+		parser.skipWhitespace()
+
+		switch parser.peek() {
+		case ',':
+			parser.advance()
+		case closing:
+		default:
+			if parser.atEnd() {
+				return nil, parser.state.errorAt(line, column, "missing closing %q", closing)
+			}
+			return nil, parser.errorHere("expected \",\" or %q", closing)
+		}
+	}
+}
+
+// Reads an entry of a flow collection. In sequences, the key is optional.
+func (parser *flowParser) parseEntry(parent *YamlNode, isSequence bool) ([]*YamlNode, error) {
+	line, column := parser.location()
+
+	if isFlowStart(parser.peek()) {
+		node := &YamlNode{
+			Type:   CHILDREN_YAML_NODE,
+			Parent: parent,
+			source: parser.lines,
+		}
+
+		opening := parser.peek()
+		children, err := parser.parseCollection(node)
+		if err != nil {
+			return nil, err
+		}
+
+		// There is no difference between a sequence and a mapping, so a mapping in a sequence
+		// is the same as its entries being in the sequence directly.
+		if opening == '{' {
+			for _, child := range children {
+				child.Parent = parent
+			}
+			return children, nil
+		}
+
+		node.Children = children
+		node.Position = parser.positionFrom(line, column)
+		return []*YamlNode{node}, nil
+	}
+
+	if parser.peek() == '*' {
+		anchor, err := parser.parseAlias()
+		if err != nil {
+			return nil, err
+		}
+		return aliasAnchor(anchor, "", parent, parser.positionFrom(line, column)), nil
+	}
+
+	anchorName := parser.parseAnchor()
+
+	if parser.atMappingColon() {
+		return nil, parser.errorHere("expected a key before the colon")
+	}
+
+	plain := !isQuote(parser.peek())
+	scalar, err := parser.parseScalar()
+	if err != nil {
+		return nil, err
+	}
+
+	// Allow whitespace between a key and its colon, as long as it's on the same line.
+	for parser.peek() == ' ' || parser.peek() == '\t' {
+		parser.advance()
+	}
+
+	if parser.atMappingColon() {
+		if anchorName != "" {
+			return nil, parser.state.errorAt(line, column, "anchors are only supported on values")
+		}
+
+		parser.advance()
+		return parser.parseValue(parent, scalar, line, column)
+	}
+
+	node := &YamlNode{
+		Type:     RAW_YAML_NODE,
+		Plain:    true,
+		Parent:   parent,
+		Position: parser.positionFrom(line, column),
+		source:   parser.lines,
+	}
+
+	if isSequence {
+		node.Content = scalar
+		node.Plain = plain
+	} else {
+		// A key without a value, like `{hidden}`, has an empty value.
+		node.Key = scalar
+	}
+
+	return parser.registerAnchor(anchorName, node), nil
+}
+
+// Reads the value of a mapping entry, after the colon.
+func (parser *flowParser) parseValue(parent *YamlNode, key string, line sourceLine, column int) ([]*YamlNode, error) {
+	parser.skipWhitespace()
+
+	if key == "<<" && (parser.peek() == '*' || parser.peek() == '[') {
+		return parser.parseMerge(parent)
+	}
+
+	if parser.peek() == '*' {
+		anchor, err := parser.parseAlias()
+		if err != nil {
+			return nil, err
+		}
+
+		return aliasAnchor(anchor, key, parent, parser.positionFrom(line, column)), nil
+	}
+
+	anchorName := parser.parseAnchor()
+
+	if isFlowStart(parser.peek()) {
+		node := &YamlNode{
+			Key:    key,
+			Type:   CHILDREN_YAML_NODE,
+			Parent: parent,
+			source: parser.lines,
+		}
+
+		children, err := parser.parseCollection(node)
+		if err != nil {
+			return nil, err
+		}
+
+		node.Children = children
+		node.Position = parser.positionFrom(line, column)
+		return parser.registerAnchor(anchorName, node), nil
+	}
+
+	node := &YamlNode{
+		Key:    key,
+		Type:   RAW_YAML_NODE,
+		Plain:  true,
+		Parent: parent,
+		source: parser.lines,
+	}
+
+	// A missing value is empty, like `{hidden: }`.
+	if !isFlowIndicator(parser.peek()) && !parser.atEnd() {
+		node.Plain = !isQuote(parser.peek())
+		content, err := parser.parseScalar()
+		if err != nil {
+			return nil, err
+		}
+		node.Content = content
+	}
+
+	node.Position = parser.positionFrom(line, column)
+	return parser.registerAnchor(anchorName, node), nil
+}
+
+// Parses a node whose value is a flow collection, such as `img: {src: "a.png", alt: "A"}`.
+// The collection may continue on the following lines.
+func parseFlowNode(state *parseState, lines []sourceLine, parent *YamlNode) ([]*YamlNode, error) {
+	if len(lines) == 0 {
+		return nil, fmt.Errorf("ParseFlowNode failed: no lines")
+	}
+
+	definition := lines[0]
+
+	key, err := parseKey(state, definition)
+	if err != nil {
+		return nil, fmt.Errorf("ParseFlowNode failed: %w", err)
+	}
+
+	_, valueIndex := getValue(definition.text)
+
+	node := &YamlNode{
+		Key:        key,
+		Type:       CHILDREN_YAML_NODE,
+		Parent:     parent,
+		AnchorName: extractAnchorName(definition.text),
+		Position:   state.positionOf(lines),
+		source:     lines,
+	}
+
+	parser := &flowParser{
+		state: state,
+		lines: lines,
+		index: valueIndex,
+	}
+
+	opening := parser.peek()
+
+	children, err := parser.parseCollection(node)
+	if err != nil {
+		return nil, fmt.Errorf("ParseFlowNode failed: %w", err)
+	}
+
+	parser.skipWhitespace()
+	if !parser.atEnd() {
+		return nil, fmt.Errorf("ParseFlowNode failed: %w", parser.errorHere("unexpected content after flow collection"))
+	}
+
+	node.Children = children
+
+	if node.AnchorName != "" {
+		state.anchorMap[node.AnchorName] = node
+		return []*YamlNode{}, nil
+	}
+
+	// A flow mapping as a sequence entry, like `- {a: b}`, is the same as its entries.
+	if key == "" && opening == '{' {
+		for _, child := range children {
+			child.Parent = parent
+		}
+		return children, nil
+	}
+
+	return []*YamlNode{node}, nil
+}
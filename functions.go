@@ -0,0 +1,160 @@
+package yaml_tmpl
+
+import (
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"strings"
+	"time"
+	"unicode"
+	"unicode/utf8"
+)
+
+// Functions that every template can call, unless an Engine replaces them.
+//
+// Functions that work on a value take it as their last argument, so that it can be piped in,
+// like ${ .title | truncate 80 }.
+var _STANDARD_FUNCTIONS = FuncMap{
+	"len":       length,
+	"upper":     strings.ToUpper,
+	"lower":     strings.ToLower,
+	"title":     title,
+	"trim":      strings.TrimSpace,
+	"truncate":  truncate,
+	"replace":   replace,
+	"contains":  contains,
+	"hasPrefix": hasPrefix,
+	"hasSuffix": hasSuffix,
+	"split":     split,
+	"join":      join,
+	"default":   defaultValue,
+	"pluralize": pluralize,
+	"date":      date,
+	"json":      toJSON,
+	"printf":    fmt.Sprintf,
+}
+
+// Returns the length of a string, slice, array or map. Nil has a length of 0.
+func length(value any) (int, error) {
+	reflected, ok := indirect(reflect.ValueOf(value))
+	if !ok || !reflected.IsValid() {
+		return 0, nil
+	}
+
+	switch reflected.Kind() {
+	case reflect.String, reflect.Slice, reflect.Array, reflect.Map, reflect.Chan:
+		return reflected.Len(), nil
+	}
+
+	return 0, fmt.Errorf("can't get the length of %s", reflected.Type())
+}
+
+// Upper cases the first letter of every word.
+func title(text string) string {
+	runes := []rune(text)
+
+	for index, char := range runes {
+		if index == 0 || unicode.IsSpace(runes[index-1]) {
+			runes[index] = unicode.ToUpper(char)
+		}
+	}
+
+	return string(runes)
+}
+
+// Cuts text down to at most length characters, ending it with … if anything was cut.
+func truncate(length int, text string) string {
+	if length < 0 || utf8.RuneCountInString(text) <= length {
+		return text
+	}
+
+	if length == 0 {
+		return ""
+	}
+
+	return string([]rune(text)[:length-1]) + "…"
+}
+
+func replace(old string, new string, text string) string {
+	return strings.ReplaceAll(text, old, new)
+}
+
+func contains(substring string, text string) bool {
+	return strings.Contains(text, substring)
+}
+
+func hasPrefix(prefix string, text string) bool {
+	return strings.HasPrefix(text, prefix)
+}
+
+func hasSuffix(suffix string, text string) bool {
+	return strings.HasSuffix(text, suffix)
+}
+
+func split(separator string, text string) []string {
+	return strings.Split(text, separator)
+}
+
+// Joins the items of a slice or array as text.
+func join(separator string, items any) (string, error) {
+	reflected, ok := indirect(reflect.ValueOf(items))
+	if !ok || !reflected.IsValid() {
+		return "", nil
+	}
+
+	if reflected.Kind() != reflect.Slice && reflected.Kind() != reflect.Array {
+		return "", fmt.Errorf("can't join %s", reflected.Type())
+	}
+
+	parts := make([]string, reflected.Len())
+	for index := range parts {
+		parts[index] = formatValue(reflected.Index(index).Interface())
+	}
+
+	return strings.Join(parts, separator), nil
+}
+
+// Returns the value, or the fallback if the value is false, zero, nil or empty.
+func defaultValue(fallback any, value any) any {
+	if isTruthy(value) {
+		return value
+	}
+
+	return fallback
+}
+
+// Returns the singular form for a count of 1, and the plural form for any other count.
+func pluralize(singular string, plural string, count int) string {
+	if count == 1 {
+		return singular
+	}
+
+	return plural
+}
+
+// Formats a time.Time with a layout like "2006-01-02". Nil is empty.
+func date(layout string, value any) (string, error) {
+	switch value := value.(type) {
+	case nil:
+		return "", nil
+	case time.Time:
+		return value.Format(layout), nil
+	case *time.Time:
+		if value == nil {
+			return "", nil
+		}
+		return value.Format(layout), nil
+	}
+
+	return "", fmt.Errorf("can't format %T as a date", value)
+}
+
+// Encodes a value as JSON. It's escaped like any other value where it ends up, such as in a script.
+func toJSON(value any) (string, error) {
+	encoded, err := json.Marshal(value)
+	if err != nil {
+		return "", err
+	}
+
+	return string(encoded), nil
+}
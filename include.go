@@ -0,0 +1,212 @@
+package yaml_tmpl
+
+import (
+	"fmt"
+	"io/fs"
+	"path"
+	"path/filepath"
+	"strings"
+)
+
+// The key of a node that is replaced with the nodes of another template.
+const _INCLUDE_KEY = "include"
+
+// Loads the templates that a template includes or is laid out in.
+type templateLoader struct {
+	// The file system templates are read from.
+	fsys fs.FS
+	// The directory fsys is, when it's a directory on disk. Files are named by their path on disk in
+	// positions and errors, so that they are named like the path given to LoadTemplate.
+	dir string
+	// The files currently being included, from the outermost one in, used to detect cycles.
+	stack []string
+}
+
+// A directory on disk, as the file system LoadTemplate loads templates from. Loaders name its files by
+// their path on disk.
+type diskDir struct {
+	fs.FS
+	path string
+}
+
+// Creates a loader for the templates that the named file in fsys includes or is laid out in. The file
+// is empty for templates that weren't read from a file, whose includes are resolved against the root of fsys.
+func newTemplateLoader(fsys fs.FS, file string) *templateLoader {
+	loader := &templateLoader{fsys: fsys}
+	if disk, ok := fsys.(diskDir); ok {
+		loader.dir = disk.path
+	}
+	if file != "" {
+		loader.stack = []string{path.Clean(file)}
+	}
+
+	return loader
+}
+
+// Returns the name of a file in the file system as it's shown in positions and errors.
+func (loader *templateLoader) displayName(name string) string {
+	if loader.dir == "" {
+		return name
+	}
+
+	return filepath.Join(loader.dir, filepath.FromSlash(name))
+}
+
+// Returns the name in the file system of a file named in a position.
+func (loader *templateLoader) fileName(file string) string {
+	if loader.dir == "" || file == "" {
+		return file
+	}
+
+	relative, err := filepath.Rel(loader.dir, file)
+	if err != nil {
+		return file
+	}
+
+	return filepath.ToSlash(relative)
+}
+
+// Resolves the path of an include or layout against the file that names it. Paths starting with a slash
+// are resolved against the root of the file system instead.
+func resolveTemplatePath(file string, include string) (string, error) {
+	resolved := path.Join(path.Dir(file), include)
+	if strings.HasPrefix(include, "/") {
+		resolved = path.Clean(strings.TrimPrefix(include, "/"))
+	}
+
+	if !fs.ValidPath(resolved) {
+		return "", fmt.Errorf("%q is outside of the root", include)
+	}
+
+	return resolved, nil
+}
+
+// Determines whether a node is an include.
+func (node *YamlNode) isInclude() bool {
+	return node.Key == _INCLUDE_KEY && node.Type == RAW_YAML_NODE
+}
+
+// Reads and parses the template an include: or layout: node names, and expands the includes in it.
+func (loader *templateLoader) load(node *YamlNode) ([]YamlNode, string, error) {
+	if loader.fsys == nil {
+		return nil, "", fmt.Errorf("%s: %s needs a file system to load %q from, use ParseTemplateFS or Engine.SetRoot", node.Position, node.Key, node.Content)
+	}
+
+	if node.Type != RAW_YAML_NODE || node.Content == "" || hasExpression(node.Content) {
+		return nil, "", fmt.Errorf("%s: %s has to be the path of a template, like partials/header.yaml", node.Position, node.Key)
+	}
+
+	name, err := resolveTemplatePath(loader.fileName(node.Position.File), node.Content)
+	if err != nil {
+		return nil, "", fmt.Errorf("%s: %w", node.Position, err)
+	}
+
+	for index, loaded := range loader.stack {
+		if loaded == name {
+			cycle := make([]string, 0, len(loader.stack)-index+1)
+			for _, file := range append(loader.stack[index:], name) {
+				cycle = append(cycle, loader.displayName(file))
+			}
+			return nil, "", fmt.Errorf("%s: %s cycle %s", node.Position, node.Key, strings.Join(cycle, " -> "))
+		}
+	}
+
+	nodes, err := loader.parseFile(name)
+	if err != nil {
+		return nil, "", fmt.Errorf("%s: %s of %s failed: %w", node.Position, node.Key, loader.displayName(name), err)
+	}
+
+	return nodes, name, nil
+}
+
+// Reads and parses a template in the file system.
+func (loader *templateLoader) read(name string) ([]YamlNode, error) {
+	file, err := loader.fsys.Open(name)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	return parseReader(file, loader.displayName(name))
+}
+
+// Reads and parses a template in the file system, and expands the includes in it.
+func (loader *templateLoader) parseFile(name string) ([]YamlNode, error) {
+	nodes, err := loader.read(name)
+	if err != nil {
+		return nil, err
+	}
+
+	loader.stack = append(loader.stack, name)
+	defer func() { loader.stack = loader.stack[:len(loader.stack)-1] }()
+
+	return loader.expandRoots(nodes)
+}
+
+// Expands the includes among top level nodes and their children.
+func (loader *templateLoader) expandRoots(nodes []YamlNode) ([]YamlNode, error) {
+	expanded := make([]YamlNode, 0, len(nodes))
+
+	for index := range nodes {
+		node := &nodes[index]
+
+		if !node.isInclude() {
+			err := loader.expandChildren(node)
+			if err != nil {
+				return nil, err
+			}
+
+			expanded = append(expanded, *node)
+			continue
+		}
+
+		included, _, err := loader.load(node)
+		if err != nil {
+			return nil, err
+		}
+
+		expanded = append(expanded, included...)
+	}
+
+	adoptChildren(expanded)
+	return expanded, nil
+}
+
+// Points the children of top level nodes at them, after the nodes have been copied into a new slice.
+func adoptChildren(nodes []YamlNode) {
+	for index := range nodes {
+		for _, child := range nodes[index].Children {
+			child.Parent = &nodes[index]
+		}
+	}
+}
+
+// Expands the includes among the children of a node, splicing the included nodes in as its children.
+func (loader *templateLoader) expandChildren(parent *YamlNode) error {
+	children := make([]*YamlNode, 0, len(parent.Children))
+
+	for _, child := range parent.Children {
+		if !child.isInclude() {
+			err := loader.expandChildren(child)
+			if err != nil {
+				return err
+			}
+
+			children = append(children, child)
+			continue
+		}
+
+		included, _, err := loader.load(child)
+		if err != nil {
+			return err
+		}
+
+		for index := range included {
+			included[index].Parent = parent
+			children = append(children, &included[index])
+		}
+	}
+
+	parent.Children = children
+	return nil
+}
@@ -0,0 +1,135 @@
+package yaml_tmpl_test
+
+import (
+	"errors"
+	"io/fs"
+	"strings"
+	"testing"
+	"testing/fstest"
+
+	"github.com/frodi-karlsson/yaml_tmpl"
+)
+
+var INCLUDE_FS = fstest.MapFS{
+	"pages/index.yaml": {
+		Data: []byte("html:\n  children:\n    - include: ../partials/header.yaml\n    - main:\n        include: /partials/attributes.yaml\n        children:\n          - p: ${ .title }\n"),
+	},
+	"partials/header.yaml": {
+		Data: []byte("header:\n  children:\n    - include: nav.yaml\nhr: \"\"\n"),
+	},
+	"partials/nav.yaml": {
+		Data: []byte("nav:\n  children:\n    - a:\n        href: /\n        innerText: ${ .site }\n"),
+	},
+	"partials/attributes.yaml": {
+		Data: []byte("id: main\nclass: [content]\n"),
+	},
+	"cycle/a.yaml": {
+		Data: []byte("div:\n  children:\n    - include: b.yaml\n"),
+	},
+	"cycle/b.yaml": {
+		Data: []byte("span:\n  children:\n    - include: a.yaml\n"),
+	},
+	"broken/page.yaml": {
+		Data: []byte("div:\n  children:\n    - include: partial.yaml\n"),
+	},
+	"broken/partial.yaml": {
+		Data: []byte("p: ok\np: \"unclosed\n"),
+	},
+}
+
+func TestInclude(t *testing.T) {
+	template, err := yaml_tmpl.ParseTemplateFS(INCLUDE_FS, "pages/index.yaml")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var out strings.Builder
+	err = template.Render(&out, map[string]any{"title": "Hello", "site": "Home"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := "<html><header><nav><a href=\"/\">Home</a></nav></header><hr><main id=\"main\" class=\"content\"><p>Hello</p></main></html>"
+	if out.String() != expected {
+		t.Errorf("Expected %s, got %s", expected, out.String())
+	}
+}
+
+func TestIncludeWithRoot(t *testing.T) {
+	nodes, err := yaml_tmpl.GetYamlNodesFromLines([]string{
+		"div:",
+		"  children:",
+		"    - include: partials/nav.yaml",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, err = yaml_tmpl.NewTemplate(nodes)
+	if err == nil || !strings.Contains(err.Error(), "include needs a file system") {
+		t.Errorf("Expected an error about the missing file system, got %v", err)
+	}
+
+	engine := yaml_tmpl.NewEngine()
+	engine.SetRoot(INCLUDE_FS)
+
+	html, err := renderWithEngine(t, engine, []string{
+		"div:",
+		"  children:",
+		"    - include: partials/nav.yaml",
+	}, map[string]any{"site": "Home"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := "<div><nav><a href=\"/\">Home</a></nav></div>"
+	if html != expected {
+		t.Errorf("Expected %s, got %s", expected, html)
+	}
+}
+
+func TestIncludeErrors(t *testing.T) {
+	_, err := yaml_tmpl.ParseTemplateFS(INCLUDE_FS, "cycle/a.yaml")
+	if err == nil || !strings.Contains(err.Error(), "include cycle cycle/a.yaml -> cycle/b.yaml -> cycle/a.yaml") {
+		t.Errorf("Expected an include cycle error, got %v", err)
+	}
+
+	_, err = yaml_tmpl.ParseTemplateFS(INCLUDE_FS, "broken/page.yaml")
+
+	var parseError *yaml_tmpl.ParseError
+	if !errors.As(err, &parseError) {
+		t.Fatalf("Expected a ParseError, got %v", err)
+	}
+
+	if parseError.Position.File != "broken/partial.yaml" || parseError.Position.Line != 2 {
+		t.Errorf("Unexpected position: %+v", parseError.Position)
+	}
+
+	if !strings.Contains(err.Error(), "broken/page.yaml:3:7: include of broken/partial.yaml failed") {
+		t.Errorf("Expected the error to show where the file was included, got %v", err)
+	}
+
+	tests := []struct {
+		lines   []string
+		message string
+	}{
+		{[]string{"include: partials/missing.yaml"}, "file does not exist"},
+		{[]string{"include: ../outside.yaml"}, "outside of the root"},
+		{[]string{"include: ${ .partial }"}, "has to be the path of a template"},
+	}
+
+	engine := yaml_tmpl.NewEngine()
+	engine.SetRoot(INCLUDE_FS)
+
+	for _, test := range tests {
+		_, err := renderWithEngine(t, engine, test.lines, nil)
+		if err == nil || !strings.Contains(err.Error(), test.message) {
+			t.Errorf("Expected an error containing %q for %v, got %v", test.message, test.lines, err)
+		}
+	}
+
+	_, err = renderWithEngine(t, engine, []string{"include: partials/missing.yaml"}, nil)
+	if !errors.Is(err, fs.ErrNotExist) {
+		t.Errorf("Expected a not exist error, got %v", err)
+	}
+}
@@ -0,0 +1,287 @@
+package yaml_tmpl
+
+import (
+	"fmt"
+	"strings"
+)
+
+// The key of a top level node naming the template a page is laid out in.
+const _LAYOUT_KEY = "layout"
+
+// The key of the top level node holding the content a page fills the slots of its layout with.
+const _SLOTS_KEY = "slots"
+
+// The key of a placeholder in a layout, which is replaced with the content a page fills it with.
+const _SLOT_KEY = "slot"
+
+// Splits the top level nodes of a page into its layout: and slots:. The layout is nil for a page without one.
+func splitLayout(nodes []YamlNode) (*YamlNode, *YamlNode, error) {
+	var layout, slots, other *YamlNode
+
+	for index := range nodes {
+		node := &nodes[index]
+
+		switch {
+		case node.Key == _LAYOUT_KEY && layout == nil:
+			layout = node
+		case node.Key == _SLOTS_KEY && slots == nil:
+			slots = node
+		case node.Key == _LAYOUT_KEY || node.Key == _SLOTS_KEY:
+			return nil, nil, fmt.Errorf("%s: a template can only have one %s:", node.Position, node.Key)
+		case node.isComponent():
+			// Components are kept when the page is laid out.
+		case other == nil:
+			other = node
+		}
+	}
+
+	if layout == nil && slots != nil {
+		return nil, nil, fmt.Errorf("%s: slots: needs a layout: to fill", slots.Position)
+	}
+
+	if layout != nil && other != nil {
+		return nil, nil, fmt.Errorf("%s: a template with a layout: can only have slots: and components next to it, not %s", other.Position, other.Key)
+	}
+
+	return layout, slots, nil
+}
+
+// Collects the content a page fills slots with, by slot name.
+func collectSlotFills(slots *YamlNode) (map[string]*YamlNode, error) {
+	fills := make(map[string]*YamlNode)
+	if slots == nil {
+		return fills, nil
+	}
+
+	if slots.Type != CHILDREN_YAML_NODE {
+		return nil, fmt.Errorf("%s: slots: has to be a mapping of slot names to content", slots.Position)
+	}
+
+	for _, fill := range slots.Children {
+		if !isValidVariableName(fill.Key) {
+			return nil, fmt.Errorf("%s: %q is not a valid slot name", fill.Position, fill.Key)
+		}
+
+		if _, exists := fills[fill.Key]; exists {
+			return nil, fmt.Errorf("%s: slot %s is filled more than once", fill.Position, fill.Key)
+		}
+
+		fills[fill.Key] = fill
+	}
+
+	return fills, nil
+}
+
+// Determines whether a node is a slot: placeholder.
+func (node *YamlNode) isSlot() bool {
+	return node.Key == _SLOT_KEY
+}
+
+// Splits a slot: placeholder into its name and its default content. The placeholder is either just
+// the name, like `slot: title`, or a name: along with the default content, which is given as
+// children:, like `slot: {name: title, children: [My site]}`, or as nodes next to the name.
+func (node *YamlNode) splitSlot() (string, []*YamlNode, error) {
+	if node.Type == RAW_YAML_NODE {
+		name := strings.TrimSpace(node.Content)
+		if !isValidVariableName(name) {
+			return "", nil, fmt.Errorf("%s: %q is not a valid slot name", node.Position, name)
+		}
+		return name, nil, nil
+	}
+
+	name := ""
+	content := make([]*YamlNode, 0, len(node.Children))
+
+	for _, child := range node.Children {
+		switch {
+		case child.Key == "name" && child.Type == RAW_YAML_NODE:
+			name = strings.TrimSpace(child.Content)
+		case child.Key == "children":
+			content = append(content, child.slotContent()...)
+		default:
+			content = append(content, child)
+		}
+	}
+
+	if !isValidVariableName(name) {
+		return "", nil, fmt.Errorf("%s: slot needs a name:, like main", node.Position)
+	}
+
+	return name, content, nil
+}
+
+// Returns the nodes that fill a slot. A scalar is text, and anything else is a list of nodes.
+func (node *YamlNode) slotContent() []*YamlNode {
+	if node.Type == RAW_YAML_NODE {
+		return []*YamlNode{{
+			Type:     RAW_YAML_NODE,
+			Content:  node.Content,
+			Plain:    node.Plain,
+			Position: node.Position,
+			source:   node.source,
+		}}
+	}
+
+	content := make([]*YamlNode, len(node.Children))
+	for index, child := range node.Children {
+		content[index] = child.Clone()
+	}
+
+	return content
+}
+
+// Fills the slot: placeholders of a layout.
+type slotFiller struct {
+	// The content of each slot, by name. Placeholders without any get their default content.
+	fills map[string]*YamlNode
+	// The names of the slots that were found.
+	found map[string]bool
+}
+
+// Returns the nodes a slot: placeholder is replaced with.
+func (filler *slotFiller) replace(node *YamlNode) ([]*YamlNode, error) {
+	name, content, err := node.splitSlot()
+	if err != nil {
+		return nil, err
+	}
+	filler.found[name] = true
+
+	if fill, exists := filler.fills[name]; exists {
+		return fill.slotContent(), nil
+	}
+
+	for _, child := range content {
+		err := filler.fill(child)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return content, nil
+}
+
+// Replaces the slot: placeholders among the children of a node. Content that fills a slot is
+// left as it is, so that it's only filled once.
+func (filler *slotFiller) fill(parent *YamlNode) error {
+	children := make([]*YamlNode, 0, len(parent.Children))
+
+	for _, child := range parent.Children {
+		if !child.isSlot() {
+			err := filler.fill(child)
+			if err != nil {
+				return err
+			}
+
+			children = append(children, child)
+			continue
+		}
+
+		content, err := filler.replace(child)
+		if err != nil {
+			return err
+		}
+
+		for _, node := range content {
+			node.Parent = parent
+			children = append(children, node)
+		}
+	}
+
+	parent.Children = children
+	return nil
+}
+
+// Replaces the slot: placeholders among top level nodes and their children.
+func (filler *slotFiller) fillRoots(nodes []YamlNode) ([]YamlNode, error) {
+	filled := make([]YamlNode, 0, len(nodes))
+
+	for index := range nodes {
+		node := &nodes[index]
+
+		// The slots of components are filled each time they are used.
+		if node.isComponent() {
+			filled = append(filled, *node)
+			continue
+		}
+
+		if !node.isSlot() {
+			err := filler.fill(node)
+			if err != nil {
+				return nil, err
+			}
+
+			filled = append(filled, *node)
+			continue
+		}
+
+		content, err := filler.replace(node)
+		if err != nil {
+			return nil, err
+		}
+
+		for _, child := range content {
+			child.Parent = nil
+			filled = append(filled, *child)
+		}
+	}
+
+	adoptChildren(filled)
+	return filled, nil
+}
+
+// Lays out a page in the template its layout: names, filling the slots of the layout with the slots: of
+// the page. Layouts can have a layout of their own, which is applied in turn. Components declared by the
+// page are kept.
+func (loader *templateLoader) applyLayouts(nodes []YamlNode) ([]YamlNode, error) {
+	for {
+		layout, slots, err := splitLayout(nodes)
+		if err != nil || layout == nil {
+			return nodes, err
+		}
+
+		fills, err := collectSlotFills(slots)
+		if err != nil {
+			return nil, err
+		}
+
+		layoutNodes, name, err := loader.load(layout)
+		if err != nil {
+			return nil, err
+		}
+		loader.stack = append(loader.stack, name)
+
+		components := make([]YamlNode, 0)
+		for _, node := range nodes {
+			if node.isComponent() {
+				components = append(components, node)
+			}
+		}
+
+		filler := &slotFiller{fills: fills, found: make(map[string]bool)}
+		nodes, err = filler.fillRoots(layoutNodes)
+		if err != nil {
+			return nil, fmt.Errorf("%s: layout %s failed: %w", layout.Position, loader.displayName(name), err)
+		}
+
+		nodes = append(nodes, components...)
+		adoptChildren(nodes)
+
+		for fillName, fill := range fills {
+			if !filler.found[fillName] {
+				return nil, fmt.Errorf("%s: layout %s has no slot %s", fill.Position, loader.displayName(name), fillName)
+			}
+		}
+	}
+}
+
+// Loads the nodes of a template: includes are expanded and the template is laid out in its layout, if
+// it has one. Only the slot: placeholders of layouts are filled, so that slot is a normal attribute or
+// element everywhere else.
+func (loader *templateLoader) loadTemplate(nodes []YamlNode) ([]YamlNode, error) {
+	nodes, err := loader.expandRoots(nodes)
+	if err != nil {
+		return nil, err
+	}
+
+	return loader.applyLayouts(nodes)
+}
@@ -0,0 +1,169 @@
+package yaml_tmpl_test
+
+import (
+	"strings"
+	"testing"
+	"testing/fstest"
+
+	"github.com/frodi-karlsson/yaml_tmpl"
+)
+
+var LAYOUT_FS = fstest.MapFS{
+	"layouts/base.yaml": {
+		Data: []byte(strings.Join([]string{
+			"html:",
+			"  children:",
+			"    - head:",
+			"        children:",
+			"          - title:",
+			"              slot:",
+			"                name: title",
+			"                children:",
+			"                  - Untitled",
+			"    - body:",
+			"        children:",
+			"          - slot:",
+			"              name: main",
+			"              p: Nothing here yet",
+			"          - footer:",
+			"              children:",
+			"                - slot:",
+			"                    name: footer",
+			"                    small: ${ .site }",
+		}, "\n")),
+	},
+	"layouts/article.yaml": {
+		Data: []byte(strings.Join([]string{
+			"layout: base.yaml",
+			"slots:",
+			"  title:",
+			"    slot: title",
+			"  main:",
+			"    - article:",
+			"        children:",
+			"          - slot: main",
+		}, "\n")),
+	},
+	"pages/index.yaml": {
+		Data: []byte(strings.Join([]string{
+			"layout: ../layouts/base.yaml",
+			"slots:",
+			"  title: Home",
+			"  main:",
+			"    - h1: ${ .heading }",
+			"    - p: Welcome",
+		}, "\n")),
+	},
+	"pages/empty.yaml": {
+		Data: []byte("layout: /layouts/base.yaml\n"),
+	},
+	"pages/post.yaml": {
+		Data: []byte(strings.Join([]string{
+			"layout: /layouts/article.yaml",
+			"slots:",
+			"  title: A post",
+			"  main:",
+			"    - p: Post",
+		}, "\n")),
+	},
+	"pages/unknown.yaml": {
+		Data: []byte("layout: /layouts/base.yaml\nslots:\n  sidebar: Links\n"),
+	},
+	"pages/extra.yaml": {
+		Data: []byte("layout: /layouts/base.yaml\np: Not in a slot\n"),
+	},
+	"pages/cycle.yaml": {
+		Data: []byte("layout: cycle.yaml\n"),
+	},
+	"pages/unnamed.yaml": {
+		Data: []byte("layout: /layouts/unnamed.yaml\n"),
+	},
+	"layouts/unnamed.yaml": {
+		Data: []byte("div:\n  children:\n    - slot:\n        p: Default\n"),
+	},
+}
+
+func renderLayout(t *testing.T, name string, data any) (string, error) {
+	t.Helper()
+
+	template, err := yaml_tmpl.ParseTemplateFS(LAYOUT_FS, name)
+	if err != nil {
+		return "", err
+	}
+
+	var out strings.Builder
+	err = template.Render(&out, data)
+	return out.String(), err
+}
+
+func TestLayout(t *testing.T) {
+	tests := []struct {
+		name     string
+		expected string
+	}{
+		{
+			"pages/index.yaml",
+			"<html><head><title>Home</title></head><body><h1>Hello</h1><p>Welcome</p><footer><small>Site</small></footer></body></html>",
+		},
+		{
+			"pages/empty.yaml",
+			"<html><head><title>Untitled</title></head><body><p>Nothing here yet</p><footer><small>Site</small></footer></body></html>",
+		},
+		{
+			"pages/post.yaml",
+			"<html><head><title>A post</title></head><body><article><p>Post</p></article><footer><small>Site</small></footer></body></html>",
+		},
+	}
+
+	for _, test := range tests {
+		html, err := renderLayout(t, test.name, map[string]any{"heading": "Hello", "site": "Site"})
+		if err != nil {
+			t.Fatalf("Unexpected error for %s: %v", test.name, err)
+		}
+
+		if html != test.expected {
+			t.Errorf("Expected %s for %s, got %s", test.expected, test.name, html)
+		}
+	}
+}
+
+func TestSlotOutsideOfLayouts(t *testing.T) {
+	html, err := renderLines(t, []string{
+		"template:",
+		"  children:",
+		"    - span: {slot: title, innerText: hi}",
+		"    - slot: {name: x}",
+	}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := "<template><span slot=\"title\">hi</span><slot name=\"x\"></slot></template>"
+	if html != expected {
+		t.Errorf("Expected %s, got %s", expected, html)
+	}
+}
+
+func TestLayoutErrors(t *testing.T) {
+	tests := []struct {
+		name    string
+		message string
+	}{
+		{"pages/unknown.yaml", "3:3: layout layouts/base.yaml has no slot sidebar"},
+		{"pages/extra.yaml", "next to it, not p"},
+		{"pages/cycle.yaml", "layout cycle pages/cycle.yaml -> pages/cycle.yaml"},
+		{"pages/unnamed.yaml", "slot needs a name:"},
+	}
+
+	for _, test := range tests {
+		_, err := renderLayout(t, test.name, nil)
+		if err == nil || !strings.Contains(err.Error(), test.message) {
+			t.Errorf("Expected an error containing %q for %s, got %v", test.message, test.name, err)
+		}
+	}
+
+	_, err := renderLines(t, []string{"slots:", "  main: text"}, nil)
+	if err == nil || !strings.Contains(err.Error(), "slots: needs a layout: to fill") {
+		t.Errorf("Expected an error about the missing layout, got %v", err)
+	}
+}
@@ -2,28 +2,76 @@ package yaml_tmpl
 
 import (
 	"fmt"
+	"io"
+	"io/fs"
 	"os"
+	"path/filepath"
 	"strings"
 )
 
-// Takes in a path to a yaml template and returns it transpiled to HTML.
-func LoadTemplate(path string) (string, error) {
-	content, err := os.ReadFile(path)
+// Splits yaml source into lines. Both \n and \r\n line breaks are supported.
+func splitLines(content string) []string {
+	// A trailing line break ends the last line rather than starting a new one.
+	content = strings.TrimSuffix(content, "\n")
+
+	lines := strings.Split(content, "\n")
+	for index, line := range lines {
+		lines[index] = strings.TrimSuffix(line, "\r")
+	}
+
+	return lines
+}
+
+// Reads yaml from r and parses it into nodes.
+//
+// Errors in the source can be retrieved as a *ParseError using errors.As.
+func ParseReader(r io.Reader) ([]YamlNode, error) {
+	nodes, err := parseReader(r, "")
+	if err != nil {
+		return nil, fmt.Errorf("ParseReader failed: %w", err)
+	}
+
+	return nodes, nil
+}
+
+// Reads yaml from r and parses it into nodes. The name is only used for positions.
+func parseReader(r io.Reader, name string) ([]YamlNode, error) {
+	content, err := io.ReadAll(r)
 	if err != nil {
-		return "", fmt.Errorf("LoadTemplate failed to read file: %w", err)
+		return nil, err
 	}
 
-	split := strings.Split(string(content), "\n")
+	return getYamlNodes(name, splitLines(string(content)))
+}
+
+// Takes in a file system and the name of a yaml template in it, and returns it transpiled to HTML.
+//
+// This allows loading templates from an embed.FS, a zip file or an in-memory fs. Options are passed on to Render.
+func LoadTemplateFS(fsys fs.FS, name string, options ...RenderOption) (string, error) {
+	template, err := ParseTemplateFS(fsys, name)
+	if err != nil {
+		return "", fmt.Errorf("LoadTemplateFS failed to get yaml nodes: %w", err)
+	}
 
-	yamlNodes, err := GetYamlNodesFromLines(split)
+	var out strings.Builder
+	err = template.Render(&out, nil, options...)
 	if err != nil {
-		return "", fmt.Errorf("LoadTemplate failed to get yaml nodes: %w", err)
+		return "", fmt.Errorf("LoadTemplateFS failed: %w", err)
 	}
 
-	out := ""
-	for _, yamlNode := range yamlNodes {
-		htmlNode := yamlNode.Transpile(nil)
-		out += htmlNode.String()
+	return out.String(), nil
+}
+
+// Takes in a path to a yaml template and returns it transpiled to HTML. Options are passed on to Render.
+//
+// Includes and layouts are resolved relative to the template, or against its directory if they start
+// with a slash. Positions in errors name files by their path, like the path given.
+func LoadTemplate(path string, options ...RenderOption) (string, error) {
+	dir := filepath.Dir(path)
+
+	out, err := LoadTemplateFS(diskDir{os.DirFS(dir), dir}, filepath.Base(path), options...)
+	if err != nil {
+		return "", fmt.Errorf("LoadTemplate failed: %w", err)
 	}
 
 	return out, nil
@@ -0,0 +1,157 @@
+package yaml_tmpl_test
+
+import (
+	"errors"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"testing/fstest"
+
+	"github.com/frodi-karlsson/yaml_tmpl"
+)
+
+var TEMPLATE_FS = fstest.MapFS{
+	"templates/index.yaml": {
+		Data: []byte("html:\n  children:\n    - body:\n        children:\n          - p: \"Hello\"\n"),
+	},
+	"templates/pages.yaml": {
+		Data: []byte("p: \"Home\"\n---\np: \"About\"\n"),
+	},
+	"templates/broken.yaml": {
+		Data: []byte("html:\n  children:\n    - p: \"Unclosed\n"),
+	},
+}
+
+func TestParseReader(t *testing.T) {
+	nodes, err := yaml_tmpl.ParseReader(strings.NewReader("p: \"Hello\"\r\ndiv:\r\n  class: \"a\"\r\n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(nodes) != 2 {
+		t.Fatalf("Expected 2 nodes, got %d", len(nodes))
+	}
+
+	res, msg := expectYamlNodeToEqual(t, nodes[1], yaml_tmpl.YamlNode{
+		Key:  "div",
+		Type: yaml_tmpl.CHILDREN_YAML_NODE,
+		Children: []*yaml_tmpl.YamlNode{
+			{Key: "class", Type: yaml_tmpl.RAW_YAML_NODE, Content: "a"},
+		},
+	})
+	if !res {
+		t.Error(msg)
+	}
+}
+
+func TestLoadTemplateFS(t *testing.T) {
+	html, err := yaml_tmpl.LoadTemplateFS(TEMPLATE_FS, "templates/index.yaml")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := "<html><body><p>Hello</p></body></html>"
+	if html != expected {
+		t.Errorf("Expected %s, got %s", expected, html)
+	}
+}
+
+func TestLoadTemplateFSDocuments(t *testing.T) {
+	_, err := yaml_tmpl.LoadTemplateFS(TEMPLATE_FS, "templates/pages.yaml")
+
+	var parseError *yaml_tmpl.ParseError
+	if !errors.As(err, &parseError) {
+		t.Errorf("Expected a ParseError for more than one document, got %v", err)
+	}
+}
+
+func TestLoadTemplateFSErrors(t *testing.T) {
+	_, err := yaml_tmpl.LoadTemplateFS(TEMPLATE_FS, "templates/missing.yaml")
+	if !errors.Is(err, fs.ErrNotExist) {
+		t.Errorf("Expected a not exist error, got %v", err)
+	}
+
+	_, err = yaml_tmpl.LoadTemplateFS(TEMPLATE_FS, "templates/broken.yaml")
+
+	var parseError *yaml_tmpl.ParseError
+	if !errors.As(err, &parseError) {
+		t.Fatalf("Expected a ParseError, got %v", err)
+	}
+
+	if parseError.Position.File != "templates/broken.yaml" || parseError.Position.Line != 3 {
+		t.Errorf("Unexpected position: %+v", parseError.Position)
+	}
+}
+
+func TestLoadTemplate(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "index.yaml")
+
+	err := os.WriteFile(path, TEMPLATE_FS["templates/index.yaml"].Data, 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	html, err := yaml_tmpl.LoadTemplate(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := "<html><body><p>Hello</p></body></html>"
+	if html != expected {
+		t.Errorf("Expected %s, got %s", expected, html)
+	}
+}
+
+func TestLoadTemplateErrors(t *testing.T) {
+	dir := filepath.Join(t.TempDir(), "templates")
+
+	files := map[string]string{
+		"broken.yaml":          "html:\n  children:\n    - p: \"Unclosed\n",
+		"page.yaml":            "div:\n  children:\n    - include: partials/broken.yaml\n",
+		"partials/broken.yaml": "p: ok\np: \"unclosed\n",
+		"expression.yaml":      "p: ${ 1 +\n",
+	}
+
+	for name, content := range files {
+		path := filepath.Join(dir, filepath.FromSlash(name))
+		err := os.MkdirAll(filepath.Dir(path), 0755)
+		if err == nil {
+			err = os.WriteFile(path, []byte(content), 0644)
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	tests := []struct {
+		name     string
+		file     string
+		line     int
+		included string
+	}{
+		{"broken.yaml", "broken.yaml", 3, ""},
+		{"page.yaml", "partials/broken.yaml", 2, filepath.Join(dir, "page.yaml") + ":3:7: include of " + filepath.Join(dir, "partials", "broken.yaml") + " failed"},
+		{"expression.yaml", "expression.yaml", 1, ""},
+	}
+
+	for _, test := range tests {
+		_, err := yaml_tmpl.LoadTemplate(filepath.Join(dir, test.name))
+
+		var parseError *yaml_tmpl.ParseError
+		if !errors.As(err, &parseError) {
+			t.Errorf("Expected a ParseError for %s, got %v", test.name, err)
+			continue
+		}
+
+		file := filepath.Join(dir, filepath.FromSlash(test.file))
+		if parseError.Position.File != file || parseError.Position.Line != test.line {
+			t.Errorf("Expected the error for %s to be in %s on line %d, got %+v", test.name, file, test.line, parseError.Position)
+		}
+
+		if test.included != "" && !strings.Contains(err.Error(), test.included) {
+			t.Errorf("Expected the error for %s to show where the file was included, got %v", test.name, err)
+		}
+	}
+}
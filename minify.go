@@ -0,0 +1,274 @@
+package yaml_tmpl
+
+import (
+	"strings"
+)
+
+// Elements after which a p element's end tag can be left out.
+var _CLOSES_PARAGRAPH = map[string]bool{
+	"address":    true,
+	"article":    true,
+	"aside":      true,
+	"blockquote": true,
+	"details":    true,
+	"dialog":     true,
+	"div":        true,
+	"dl":         true,
+	"fieldset":   true,
+	"figcaption": true,
+	"figure":     true,
+	"footer":     true,
+	"form":       true,
+	"h1":         true,
+	"h2":         true,
+	"h3":         true,
+	"h4":         true,
+	"h5":         true,
+	"h6":         true,
+	"header":     true,
+	"hgroup":     true,
+	"hr":         true,
+	"main":       true,
+	"menu":       true,
+	"nav":        true,
+	"ol":         true,
+	"p":          true,
+	"pre":        true,
+	"search":     true,
+	"section":    true,
+	"table":      true,
+	"ul":         true,
+}
+
+// Elements in which a p element can't leave out its end tag when it's the last child.
+var _KEEPS_PARAGRAPH_END_TAG = map[string]bool{
+	"a":        true,
+	"audio":    true,
+	"del":      true,
+	"ins":      true,
+	"map":      true,
+	"noscript": true,
+	"video":    true,
+}
+
+// Elements whose end tag can be left out when followed by one of the listed elements. If the
+// element can also leave it out as the last child of its parent, lastChild is true.
+var _OPTIONAL_END_TAGS = map[string]struct {
+	followedBy []string
+	lastChild  bool
+}{
+	"li":       {followedBy: []string{"li"}, lastChild: true},
+	"dt":       {followedBy: []string{"dt", "dd"}},
+	"dd":       {followedBy: []string{"dt", "dd"}, lastChild: true},
+	"rt":       {followedBy: []string{"rt", "rp"}, lastChild: true},
+	"rp":       {followedBy: []string{"rt", "rp"}, lastChild: true},
+	"optgroup": {followedBy: []string{"optgroup", "hr"}, lastChild: true},
+	"option":   {followedBy: []string{"option", "optgroup", "hr"}, lastChild: true},
+	"thead":    {followedBy: []string{"tbody", "tfoot"}},
+	"tbody":    {followedBy: []string{"tbody", "tfoot"}, lastChild: true},
+	"tfoot":    {lastChild: true},
+	"tr":       {followedBy: []string{"tr"}, lastChild: true},
+	"td":       {followedBy: []string{"td", "th"}, lastChild: true},
+	"th":       {followedBy: []string{"td", "th"}, lastChild: true},
+}
+
+// Characters that stop an attribute value from being written without quotes.
+const _UNQUOTED_ATTRIBUTE_STOPS = " \t\n\f\r\"'=<>`"
+
+// Whether a character is whitespace in HTML.
+func isHtmlWhitespace(char byte) bool {
+	return char == ' ' || char == '\t' || char == '\n' || char == '\f' || char == '\r'
+}
+
+// Determines whether the end tag of a node can be left out, following the rules of the HTML spec.
+// Next is the node that follows it, or nil if it's the last child of its parent.
+//
+// Comments are stripped when minifying, so the rules about comments following an element always hold.
+func (node *HtmlNode) canOmitEndTag(next *HtmlNode) bool {
+	tag := strings.ToLower(node.Tag)
+
+	// Whatever follows these ends up in the body anyway.
+	if tag == "html" || tag == "body" {
+		return true
+	}
+
+	// For root nodes, we can't know what follows.
+	if node.Parent == nil {
+		return false
+	}
+
+	if next != nil && next.Type != TAG_HTML_NODE {
+		return false
+	}
+
+	nextTag := ""
+	if next != nil {
+		nextTag = strings.ToLower(next.Tag)
+	}
+
+	switch tag {
+	case "head":
+		// Whitespace after head would end up in it, but the next node isn't text.
+		return true
+	case "p":
+		if next == nil {
+			parentTag := strings.ToLower(node.Parent.Tag)
+			return !_KEEPS_PARAGRAPH_END_TAG[parentTag] && !strings.Contains(parentTag, "-")
+		}
+		return _CLOSES_PARAGRAPH[nextTag]
+	}
+
+	rule, ok := _OPTIONAL_END_TAGS[tag]
+	if !ok {
+		return false
+	}
+
+	if next == nil {
+		return rule.lastChild
+	}
+
+	for _, followedBy := range rule.followedBy {
+		if nextTag == followedBy {
+			return true
+		}
+	}
+
+	return false
+}
+
+// Writes an attribute with as few characters as possible. Empty values are left out entirely,
+// and values are only quoted if they contain a character that needs it.
+func writeMinifiedAttribute(writer *htmlWriter, name string, value string) {
+	writer.writeString(name)
+
+	value = attributeValue(name, value)
+	if value == "" {
+		return
+	}
+
+	if strings.ContainsAny(value, _UNQUOTED_ATTRIBUTE_STOPS) {
+		writer.writeString("=\"")
+		writer.writeEscaped(attributeEscaper, value)
+		writer.writeString("\"")
+		return
+	}
+
+	writer.writeString("=")
+	writer.writeEscaped(attributeEscaper, value)
+}
+
+// Replaces each run of whitespace with a single space, which renders the same outside of
+// whitespace sensitive elements.
+func collapseWhitespace(content string) string {
+	var builder strings.Builder
+	builder.Grow(len(content))
+
+	for index := 0; index < len(content); index++ {
+		if !isHtmlWhitespace(content[index]) {
+			builder.WriteByte(content[index])
+			continue
+		}
+
+		builder.WriteByte(' ')
+		for index+1 < len(content) && isHtmlWhitespace(content[index+1]) {
+			index++
+		}
+	}
+
+	return builder.String()
+}
+
+// Returns the index just past the end of the tag that content starts with, skipping over
+// quoted attribute values. Returns the length of content if the tag is unclosed.
+func findTagEnd(content string) int {
+	var quote byte
+
+	for index := 1; index < len(content); index++ {
+		char := content[index]
+		if quote != 0 {
+			if char == quote {
+				quote = 0
+			}
+		} else if char == '"' || char == '\'' {
+			quote = char
+		} else if char == '>' {
+			return index + 1
+		}
+	}
+
+	return len(content)
+}
+
+// Returns the lowercased name of the start tag that content starts with, or "" if it doesn't start with one.
+func startTagName(content string) string {
+	end := 1
+	for end < len(content) && (isLetter(content[end]) || content[end] == '-' || '0' <= content[end] && content[end] <= '9') {
+		end++
+	}
+
+	if end == 1 || !isLetter(content[1]) {
+		return ""
+	}
+
+	return strings.ToLower(content[1:end])
+}
+
+func isLetter(char byte) bool {
+	return 'a' <= char && char <= 'z' || 'A' <= char && char <= 'Z'
+}
+
+// Minifies an HTML string from a `raw:` node. Comments are removed and whitespace in text is collapsed.
+// Tags and the content of whitespace sensitive elements are kept as they are.
+func minifyRawHTML(content string) string {
+	var builder strings.Builder
+	builder.Grow(len(content))
+
+	// Whether the last thing written was collapsed whitespace. Whitespace on both sides of a comment
+	// becomes a single space too.
+	spaced := false
+
+	for index := 0; index < len(content); {
+		rest := content[index:]
+
+		switch {
+		case strings.HasPrefix(rest, "<!--"):
+			end := strings.Index(rest[4:], "-->")
+			if end == -1 {
+				return builder.String()
+			}
+			index += 4 + end + 3
+		case rest[0] == '<':
+			end := findTagEnd(rest)
+			builder.WriteString(rest[:end])
+			index += end
+			spaced = false
+
+			tag := startTagName(rest)
+			if !_PREFORMATTED_ELEMENTS[tag] {
+				continue
+			}
+
+			// Keep the content as is, up to the end tag.
+			contentEnd := strings.Index(strings.ToLower(content[index:]), "</"+tag)
+			if contentEnd == -1 {
+				contentEnd = len(content) - index
+			}
+			builder.WriteString(content[index : index+contentEnd])
+			index += contentEnd
+		case isHtmlWhitespace(rest[0]):
+			if !spaced {
+				builder.WriteByte(' ')
+				spaced = true
+			}
+			for index < len(content) && isHtmlWhitespace(content[index]) {
+				index++
+			}
+		default:
+			builder.WriteByte(rest[0])
+			index++
+			spaced = false
+		}
+	}
+
+	return builder.String()
+}
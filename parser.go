@@ -2,6 +2,7 @@ package yaml_tmpl
 
 import (
 	"fmt"
+	"maps"
 	"strings"
 )
 
@@ -23,6 +24,8 @@ const (
 	_ALIAS_YAML_NODE
 	// An override node is an alias node with the tag "<<"
 	_OVERRIDE_YAML_NODE
+	// A flow node is a node with a flow sequence or mapping as its value, like `[a, b]` or `{a: b}`.
+	_FLOW_YAML_NODE
 )
 
 type YamlNode struct {
@@ -34,10 +37,53 @@ type YamlNode struct {
 	//
 	// This contains the lines up until an indentation of the same level or lower.
 	Content string
+	// Only used if Type == RAW_NODE
+	//
+	// True if Content is a plain scalar, or there is no value at all, rather than a quoted or block scalar.
+	// Only plain scalars can be booleans or null, like `disabled: true`.
+	Plain bool
 	// Nil for a root node.
 	Parent *YamlNode
 	// Empty string if this node is not an anchor
 	AnchorName string
+	// Where the node is defined in the source.
+	Position Position
+	// The source lines the node is parsed from, used to point errors in its expressions at the source.
+	source []sourceLine
+}
+
+// State shared while parsing a single yaml source.
+type parseState struct {
+	// The name of the source, used in positions.
+	file string
+	// Nodes defined with &anchor, by anchor name.
+	anchorMap map[string]*YamlNode
+}
+
+// Creates a ParseError pointing at a 1-based column of a line.
+func (state *parseState) errorAt(line sourceLine, column int, format string, args ...any) *ParseError {
+	return &ParseError{
+		Position: Position{
+			File:    state.file,
+			Line:    line.number,
+			Column:  column,
+			EndLine: line.number,
+		},
+		Message: fmt.Sprintf(format, args...),
+		Excerpt: createExcerpt(line, column),
+	}
+}
+
+// Returns the position of a node defined by the given lines.
+func (state *parseState) positionOf(lines []sourceLine) Position {
+	definition := lines[0]
+
+	return Position{
+		File:    state.file,
+		Line:    definition.number,
+		Column:  keyColumn(definition.text),
+		EndLine: lines[len(lines)-1].number,
+	}
 }
 
 func getIndentation(line string) int {
@@ -55,34 +101,42 @@ func getIndentation(line string) int {
 }
 
 // Splits a group of yaml lines into groups of direct children.
-func collectGroups(lines []string) ([][]string, error) {
+func collectGroups(state *parseState, lines []sourceLine) ([][]sourceLine, error) {
 	length := len(lines)
 	if length == 0 {
-		return [][]string{}, nil
+		return [][]sourceLine{}, nil
 	}
 
 	if length == 1 {
-		return [][]string{lines}, nil
+		return [][]sourceLine{lines}, nil
 	}
 
-	topLevelIndent := getIndentation(lines[0])
+	topLevelIndent := getIndentation(lines[0].text)
 
-	var elements = make([][]string, 0, length)
-	var element = make([]string, 0, length)
+	var elements = make([][]sourceLine, 0, length)
+	var element = make([]sourceLine, 0, length)
 	elementLength := 0
 
 	for _, line := range lines {
 		if elementLength == 0 {
-			element = make([]string, 0, length)
+			element = make([]sourceLine, 0, length)
+			element = append(element, line)
+			elementLength++
+			continue
+		}
+
+		// Empty lines only occur inside block scalars, so they always belong to the current element.
+		// The same goes for lines continuing a flow collection.
+		if isBlank(line.text) || line.continued {
 			element = append(element, line)
 			elementLength++
 			continue
 		}
 
-		indentation := getIndentation(line)
+		indentation := getIndentation(line.text)
 
 		if indentation < topLevelIndent {
-			return nil, fmt.Errorf("CollectGroups failed: indentation level is lower than top level")
+			return nil, fmt.Errorf("CollectGroups failed: %w", state.errorAt(line, keyColumn(line.text), "line is indented less than the lines before it"))
 		}
 
 		isTopLevel := indentation == topLevelIndent
@@ -90,7 +144,7 @@ func collectGroups(lines []string) ([][]string, error) {
 		// If the line is at the same indentation as the first line, we have a new element.
 		if isTopLevel {
 			elements = append(elements, element)
-			element = []string{line}
+			element = []sourceLine{line}
 			elementLength = 1
 		} else {
 			element = append(element, line)
@@ -109,7 +163,7 @@ func collectGroups(lines []string) ([][]string, error) {
 //
 // The first line passed will be the definition for the parent node,
 // and all following nodes are children.
-func determineNodeType(lines []string) (YamlNodeType, error) {
+func determineNodeType(state *parseState, lines []sourceLine) (YamlNodeType, error) {
 	lineLength := len(lines)
 
 	if lineLength == 0 {
@@ -118,18 +172,17 @@ func determineNodeType(lines []string) (YamlNodeType, error) {
 
 	definition := lines[0]
 
-	// If the definition line contains a quotation as defined in QUOTE_TYPES, it is a raw node.
-	for _, char := range definition {
-		for _, quoteType := range _QUOTE_TYPES {
-			if char == quoteType {
-				return RAW_YAML_NODE, nil
-			}
-		}
+	if findMappingColon(definition.text) == -1 && !isSequenceEntry(definition.text) {
+		return UNKNOWN_YAML_NODE, fmt.Errorf("DetermineNodeType failed: %w", state.errorAt(definition, keyColumn(definition.text), "expected a colon after the key"))
 	}
 
-	// If the definition line contains an asterisk, it can be an alias or an override.
-	if strings.Contains(definition, "*") {
-		key, err := parseKey(definition)
+	value, _ := getValue(definition.text)
+	hasValue := value != "" && value[0] != '#'
+
+	// If the value is an asterisk, it can be an alias or an override. Overrides can also merge
+	// a sequence of aliases, like `<<: [*a, *b]`.
+	if hasValue && (value[0] == '*' || value[0] == '[') {
+		key, err := parseKey(state, definition)
 		if err != nil {
 			return UNKNOWN_YAML_NODE, fmt.Errorf("DetermineNodeType failed: %w", err)
 		}
@@ -137,16 +190,28 @@ func determineNodeType(lines []string) (YamlNodeType, error) {
 		if key == "<<" {
 			return _OVERRIDE_YAML_NODE, nil
 		}
+	}
+
+	if hasValue && value[0] == '*' {
 		return _ALIAS_YAML_NODE, nil
 	}
 
-	// If we don't have more than one line and it's not raw, it must be unknown.
+	if hasValue && isFlowStart(value[0]) {
+		return _FLOW_YAML_NODE, nil
+	}
+
+	// Any other value, whether quoted, plain or a block scalar, is a raw node.
+	if hasValue {
+		return RAW_YAML_NODE, nil
+	}
+
+	// If there is no value and nothing below it, it's an empty raw node, like null in YAML.
 	if lineLength < 2 {
-		return UNKNOWN_YAML_NODE, fmt.Errorf("DetermineNodeType failed: Could not determine node type of one line and no quotes in %v", lines)
+		return RAW_YAML_NODE, nil
 	}
 
-	firstIndentation := getIndentation(definition)
-	secondIndentation := getIndentation(lines[1])
+	firstIndentation := getIndentation(definition.text)
+	secondIndentation := getIndentation(lines[1].text)
 
 	// If the next line has a higher indentation, it is a children node.
 	// If it doesn't, we have an empty node and resolve it as an empty string raw node.
@@ -155,140 +220,169 @@ func determineNodeType(lines []string) (YamlNodeType, error) {
 	}
 
 	// If there are no lines at the same indentation as the first line, it is a children node.
-	nonFirstLineAtFirstIndentationExists := false
-
 	for _, line := range lines[1:] {
-		indentation := getIndentation(line)
+		if isBlank(line.text) || line.continued {
+			continue
+		}
+
+		indentation := getIndentation(line.text)
 
 		if indentation == firstIndentation {
-			nonFirstLineAtFirstIndentationExists = true
-			break
+			return UNKNOWN_YAML_NODE, fmt.Errorf("DetermineNodeType failed: %w", state.errorAt(line, keyColumn(line.text), "line is at the same indentation as the node it belongs to"))
 		}
 	}
 
-	if !nonFirstLineAtFirstIndentationExists {
-		return CHILDREN_YAML_NODE, nil
-	}
-
-	return UNKNOWN_YAML_NODE, fmt.Errorf("DetermineNodeType failed: There are lines at the same indentation as the first line in %v", lines)
+	return CHILDREN_YAML_NODE, nil
 }
 
 // Extracts the content of a raw node.
-func extractRawContent(lines []string) (string, error) {
+func extractRawContent(state *parseState, lines []sourceLine) (string, error) {
 	lineLength := len(lines)
 
 	if lineLength == 0 {
 		return "", fmt.Errorf("ExtractRawContent failed: no lines")
 	}
 
+	definition := lines[0]
+	value, valueIndex := getValue(definition.text)
+
+	if isBlockScalarHeader(value) {
+		content, err := extractBlockScalar(state, lines, value, valueIndex)
+		if err != nil {
+			return "", fmt.Errorf("ExtractRawContent failed: %w", err)
+		}
+		return content, nil
+	}
+
 	if lineLength > 1 {
-		return "", fmt.Errorf("ExtractRawContent failed: too many lines")
+		return "", fmt.Errorf("ExtractRawContent failed: %w", state.errorAt(lines[1], keyColumn(lines[1].text), "unexpected indented line after a value"))
 	}
 
-	definition := lines[0]
+	if value == "" || value[0] == '#' {
+		return "", nil
+	}
+
+	if isQuote(value[0]) {
+		content, err := parseQuotedScalar(state, definition, valueIndex)
+		if err != nil {
+			return "", fmt.Errorf("ExtractRawContent failed: %w", err)
+		}
+		return content, nil
+	}
+
+	content, err := parsePlainScalar(state, definition, valueIndex)
+	if err != nil {
+		return "", fmt.Errorf("ExtractRawContent failed: %w", err)
+	}
+	return content, nil
+}
+
+// Parses the key of a node. Quoted keys are unquoted, and sequence entries without a key
+// such as `- "value"` have an empty key.
+func parseKey(state *parseState, line sourceLine) (string, error) {
+	colonIndex := findMappingColon(line.text)
+	if colonIndex == -1 && isSequenceEntry(line.text) {
+		return "", nil
+	}
 
-	colonIndex := strings.IndexRune(definition, ':')
 	if colonIndex == -1 {
-		return "", fmt.Errorf("ExtractRawContent failed: no colon in %s", definition)
+		return "", fmt.Errorf("ExtractKey failed: %w", state.errorAt(line, keyColumn(line.text), "expected a colon after the key"))
 	}
 
-	rightHandSide := definition[colonIndex+1:]
+	key := strings.TrimRight(line.text[keyStart(line.text):colonIndex], " \t")
+	return unquoteKey(key), nil
+}
 
-	var insideQuote = false
-	var quoteType rune
-	var value = make([]rune, 0, len(rightHandSide))
-	var escaped = false
+// Returns the value of a definition line along with the index it starts at. The key, anchor
+// and leading whitespace are not part of the value, but trailing comments are.
+func getValue(line string) (string, int) {
+	index := valueStart(line)
+	if index == -1 {
+		return "", len(line)
+	}
 
-	for _, char := range rightHandSide {
-		if char == '#' && !insideQuote { // The rest of the line is a comment.
-			break
+	if index < len(line) && line[index] == '&' {
+		index++
+		for index < len(line) && !isSpecial(line[index]) {
+			index++
 		}
-
-		// Treat escaped quotes as a single character.
-		if char == '\\' && !escaped {
-			escaped = true
-			continue
+		for index < len(line) && (line[index] == ' ' || line[index] == '\t') {
+			index++
 		}
+	}
 
-		if (char == '\'' || char == '"') && !escaped {
-			if !insideQuote {
-				insideQuote = true
-				quoteType = char
-			} else if quoteType == char {
-				insideQuote = false
-			}
-		} else if insideQuote {
-			value = append(value, char)
-		}
+	return line[index:], index
+}
 
-		escaped = false
+// Returns the index the value of a line starts at, including any anchor, or -1 if it has no value.
+//
+// Sequence entries without a key, such as `- "value"`, are all value.
+func valueStart(line string) int {
+	index := findMappingColon(line) + 1
+	if index == 0 {
+		if !isSequenceEntry(line) {
+			return -1
+		}
+		index = keyStart(line)
 	}
 
-	if insideQuote {
-		return "", fmt.Errorf("ExtractRawContent failed: missing closing quote")
+	for index < len(line) && (line[index] == ' ' || line[index] == '\t') {
+		index++
 	}
 
-	return string(value), nil
+	return index
 }
 
-// Parses the key of a node.
-func parseKey(line string) (string, error) {
-	colonIndex := strings.IndexRune(line, ':')
-	if colonIndex == -1 {
-		return "", fmt.Errorf("ExtractKey failed: no colon in %s", line)
-	}
+// Whether a line is an entry in a block sequence, meaning that it starts with a dash.
+func isSequenceEntry(line string) bool {
+	trimmed := strings.TrimLeft(line, " \t")
+	return trimmed == "-" || strings.HasPrefix(trimmed, "- ")
+}
 
-	leftHandSide := line[:colonIndex]
-	trimmed := strings.TrimLeft(leftHandSide, "- ")
-	return trimmed, nil
+// Whether a line contains only whitespace.
+func isBlank(line string) bool {
+	return strings.TrimSpace(line) == ""
 }
 
 func isSpecial(byte byte) bool {
-	return byte == ' ' || byte == '\t' || byte == '\n' || byte == '\r' || byte == '#' || byte == ':' || byte == '&' || byte == '*'
+	return byte == ' ' || byte == '\t' || byte == '\n' || byte == '\r' || byte == '#' || byte == ':' || byte == '&' || byte == '*' || isFlowIndicator(byte)
 }
 
-// Extracts the anchor name from a line and returns (modifiedLine, anchorName).
-func extractAnchorName(line string) (string, string) {
-	anchorIndex := strings.IndexRune(line, '&')
-	if anchorIndex == -1 {
-		return line, ""
+// Extracts the anchor name from the value of a line, or an empty string if it has none.
+func extractAnchorName(line string) string {
+	index := valueStart(line)
+	if index == -1 {
+		return ""
 	}
 
-	lineLength := len(line)
-
-	if anchorIndex == lineLength-1 {
-		return line[:anchorIndex], ""
+	value := line[index:]
+	if len(value) == 0 || value[0] != '&' {
+		return ""
 	}
 
-	anchorName := make([]rune, 0, lineLength-anchorIndex-1)
-
-	var endIndex int
-	for index, char := range line[anchorIndex+1:] {
-		endIndex = index + anchorIndex + 1
-		if isSpecial(byte(char)) {
-			break
-		}
-		anchorName = append(anchorName, char)
+	end := 1
+	for end < len(value) && !isSpecial(value[end]) {
+		end++
 	}
 
-	return line[:anchorIndex] + line[endIndex:], string(anchorName)
+	return value[1:end]
 }
 
-func parseChildrenNode(lines []string, parent *YamlNode, anchorMap map[string]*YamlNode) (*YamlNode, error) {
+func parseChildrenNode(state *parseState, lines []sourceLine, parent *YamlNode) (*YamlNode, error) {
 	if len(lines) == 0 {
 		return nil, fmt.Errorf("ParseChildrenNode failed: no lines")
 	}
 
 	definition := lines[0]
 
-	childLines, err := collectGroups(lines[1:])
+	childLines, err := collectGroups(state, lines[1:])
 	if err != nil {
 		return nil, fmt.Errorf("ParseChildrenNode failed: %w", err)
 	}
 
-	definition, anchorName := extractAnchorName(definition)
+	anchorName := extractAnchorName(definition.text)
 
-	key, err := parseKey(definition)
+	key, err := parseKey(state, definition)
 	if err != nil {
 		return nil, fmt.Errorf("ParseChildrenNode failed: %w", err)
 	}
@@ -298,151 +392,321 @@ func parseChildrenNode(lines []string, parent *YamlNode, anchorMap map[string]*Y
 	childrenNode.Type = CHILDREN_YAML_NODE
 	childrenNode.Parent = parent
 	childrenNode.AnchorName = anchorName
+	childrenNode.Position = state.positionOf(lines)
+	childrenNode.source = lines
 
-	children := make([]*YamlNode, 0, len(childLines))
-
-	for _, childLines := range childLines {
-		childNodes, err := parseNode(childLines, &childrenNode, anchorMap)
-		if err != nil {
-			return nil, fmt.Errorf("ParseChildrenNode failed: %w", err)
-		}
-
-		children = append(children, childNodes...)
+	children, err := parseEntries(state, childLines, &childrenNode)
+	if err != nil {
+		return nil, fmt.Errorf("ParseChildrenNode failed: %w", err)
 	}
 
 	childrenNode.Children = children
 
 	if anchorName != "" {
-		anchorMap[anchorName] = &childrenNode
+		state.anchorMap[anchorName] = &childrenNode
 	}
 
 	return &childrenNode, nil
 }
 
-func parseRawNode(lines []string, parent *YamlNode, anchorMap map[string]*YamlNode) (*YamlNode, error) {
+func parseRawNode(state *parseState, lines []sourceLine, parent *YamlNode) (*YamlNode, error) {
 	if len(lines) == 0 {
 		return nil, fmt.Errorf("ParseRawNode failed: no lines")
 	}
 
-	content, err := extractRawContent(lines)
+	content, err := extractRawContent(state, lines)
 	if err != nil {
 		return nil, fmt.Errorf("ParseRawNode failed: %w", err)
 	}
 
-	definition, anchorName := extractAnchorName(lines[0])
+	anchorName := extractAnchorName(lines[0].text)
 
-	key, err := parseKey(definition)
+	key, err := parseKey(state, lines[0])
 	if err != nil {
 		return nil, fmt.Errorf("ParseRawNode failed: %w", err)
 	}
 
+	value, _ := getValue(lines[0].text)
+
 	nodePtr := &YamlNode{
 		Key:        key,
 		Type:       RAW_YAML_NODE,
 		Content:    content,
+		Plain:      isPlainValue(value),
 		Parent:     parent,
 		AnchorName: anchorName,
+		Position:   state.positionOf(lines),
+		source:     lines,
 	}
 
 	if anchorName != "" {
-		anchorMap[anchorName] = nodePtr
+		state.anchorMap[anchorName] = nodePtr
 	}
 
 	return nodePtr, nil
 }
 
-func getAnchor(definition string, anchorMap map[string]*YamlNode) (*YamlNode, error) {
-	asteriskIndex := strings.IndexRune(definition, '*')
-	if asteriskIndex == -1 {
+func getAnchor(state *parseState, definition sourceLine) (*YamlNode, error) {
+	value, asteriskIndex := getValue(definition.text)
+	if len(value) == 0 || value[0] != '*' {
 		return nil, fmt.Errorf("GetAnchor failed: no asterisk")
 	}
 
-	anchorName := make([]rune, 0, len(definition)-asteriskIndex-1)
-	for _, char := range definition[asteriskIndex+1:] {
+	anchorName := make([]rune, 0, len(definition.text)-asteriskIndex-1)
+	for _, char := range definition.text[asteriskIndex+1:] {
 		if isSpecial(byte(char)) {
 			break
 		}
 		anchorName = append(anchorName, char)
 	}
 
-	anchor, exists := anchorMap[string(anchorName)]
+	anchor, exists := state.anchorMap[string(anchorName)]
 	if !exists {
-		allValues := ""
-		for key, value := range anchorMap {
-			allValues += key + ", " + value.Key + "\n"
-		}
-		return nil, fmt.Errorf("GetAnchor failed: anchor not found, all values:\n%s", allValues)
+		return nil, fmt.Errorf("GetAnchor failed: %w", state.errorAt(definition, asteriskIndex+1, "anchor %q is not defined", string(anchorName)))
 	}
 
 	return anchor, nil
 }
 
 // Parses an alias node.
-func parseAliasNode(lines []string, parent *YamlNode, anchorMap map[string]*YamlNode) (*YamlNode, error) {
+func parseAliasNode(state *parseState, lines []sourceLine, parent *YamlNode) ([]*YamlNode, error) {
 	if len(lines) == 0 {
 		return nil, fmt.Errorf("ParseAliasNode failed: no lines")
 	}
 
 	definition := lines[0]
-	key, err := parseKey(definition)
+	key, err := parseKey(state, definition)
 	if err != nil {
 		return nil, fmt.Errorf("ParseAliasNode failed: %w", err)
 	}
 
-	anchor, err := getAnchor(definition, anchorMap)
+	anchor, err := getAnchor(state, definition)
 	if err != nil {
 		return nil, fmt.Errorf("ParseAliasNode failed: %w", err)
 	}
 
+	return aliasAnchor(anchor, key, parent, state.positionOf(lines)), nil
+}
+
+// Returns a deep copy of the node. Its children are copied too, and point at their copied parents, so
+// changing the copy doesn't change the node. The copy has the same parent as the node.
+func (node *YamlNode) Clone() *YamlNode {
+	clone := *node
+
+	if node.Children != nil {
+		clone.Children = make([]*YamlNode, len(node.Children))
+		for index, child := range node.Children {
+			clone.Children[index] = child.Clone()
+			clone.Children[index].Parent = &clone
+		}
+	}
+
+	return &clone
+}
+
+// Creates the nodes that alias an anchor. A keyed alias is a children node containing a clone of the
+// anchor, while an alias without a key, such as `- *anchor`, only reuses the value of the anchor and
+// not its key: the clones of its children, or a text node with its content.
+func aliasAnchor(anchor *YamlNode, key string, parent *YamlNode, position Position) []*YamlNode {
+	if key == "" && anchor.Type == CHILDREN_YAML_NODE {
+		children := make([]*YamlNode, len(anchor.Children))
+		for index, child := range anchor.Children {
+			children[index] = child.Clone()
+			children[index].Parent = parent
+		}
+		return children
+	}
+
+	if key == "" {
+		clone := anchor.Clone()
+		clone.Key = ""
+		clone.Parent = parent
+		return []*YamlNode{clone}
+	}
+
 	childrenNode := YamlNode{
-		Key:    key,
-		Type:   CHILDREN_YAML_NODE,
-		Parent: parent,
+		Key:      key,
+		Type:     CHILDREN_YAML_NODE,
+		Parent:   parent,
+		Position: position,
 	}
 
-	copy := *anchor
-	copy.Parent = &childrenNode
-	childrenNode.Children = []*YamlNode{&copy}
+	clone := anchor.Clone()
+	clone.Parent = &childrenNode
+	childrenNode.Children = []*YamlNode{clone}
 
-	return &childrenNode, nil
+	return []*YamlNode{&childrenNode}
 }
 
-// Parses an override node.
-func parseOverrideNode(lines []string, parent *YamlNode, anchorMap map[string]*YamlNode) ([]*YamlNode, error) {
+// Parses an override node, which merges the children of an anchor, or of a sequence of anchors,
+// into its parent.
+func parseOverrideNode(state *parseState, lines []sourceLine, parent *YamlNode) ([]*YamlNode, error) {
 	if len(lines) == 0 {
 		return nil, fmt.Errorf("ParseOverrideNode failed: no lines")
 	}
 
 	definition := lines[0]
+	_, column := getValue(definition.text)
+
+	if definition.text[column] == '[' {
+		parser := &flowParser{
+			state: state,
+			lines: lines,
+			index: column,
+		}
+
+		childNodes, err := parser.parseMerge(parent)
+		if err != nil {
+			return nil, fmt.Errorf("ParseOverrideNode failed: %w", err)
+		}
+
+		parser.skipWhitespace()
+		if !parser.atEnd() {
+			return nil, fmt.Errorf("ParseOverrideNode failed: %w", parser.errorHere("unexpected content after flow collection"))
+		}
+
+		return childNodes, nil
+	}
 
-	anchor, err := getAnchor(definition, anchorMap)
+	anchor, err := getAnchor(state, definition)
 	if err != nil {
 		return nil, fmt.Errorf("ParseOverrideNode failed: %w", err)
 	}
 
+	childNodes, err := mergeAnchor(state, definition, column+1, anchor, parent)
+	if err != nil {
+		return nil, fmt.Errorf("ParseOverrideNode failed: %w", err)
+	}
+
+	return childNodes, nil
+}
+
+// Returns clones of the children of an anchor, to be merged into parent. The line and column
+// of the alias are used for errors.
+func mergeAnchor(state *parseState, line sourceLine, column int, anchor *YamlNode, parent *YamlNode) ([]*YamlNode, error) {
 	if anchor.Type != CHILDREN_YAML_NODE {
-		return nil, fmt.Errorf("ParseOverrideNode failed: anchor is not a children node")
+		return nil, state.errorAt(line, column, "anchor %q can't be merged, as it has no children", anchor.AnchorName)
 	}
 
 	childNodes := make([]*YamlNode, 0, len(anchor.Children))
 	for _, child := range anchor.Children {
-		copy := *child
-		copy.Parent = parent
-		childNodes = append(childNodes, &copy)
+		clone := child.Clone()
+		clone.Parent = parent
+		childNodes = append(childNodes, clone)
 	}
 
 	return childNodes, nil
 }
 
-// Parses a node. It returns an array, because override nodes may return multiple nodes.
-func parseNode(lines []string, parent *YamlNode, anchorMap map[string]*YamlNode) ([]*YamlNode, error) {
-	nodeType, err := determineNodeType(lines)
+// How the nodes of an entry take part in resolving merge keys.
+type mergeRole int
+
+const (
+	// An entry of the mapping itself, whose key wins over merged ones.
+	_LOCAL_ENTRY mergeRole = iota
+	// The nodes merged in with <<, which lose to local keys and to earlier merges.
+	_MERGED_ENTRY
+	// A sequence entry, like `- p: "text"`, which is left as it is. A `- <<: *anchor` only merges into itself.
+	_SEQUENCE_ENTRY
+)
+
+// Collects the entries of a mapping, resolving merge keys like the YAML merge spec does: keys of the
+// mapping itself win over merged ones, and earlier merges win over later ones.
+type mergedMapping struct {
+	entries [][]*YamlNode
+	roles   []mergeRole
+}
+
+func (mapping *mergedMapping) add(nodes []*YamlNode, role mergeRole) {
+	mapping.entries = append(mapping.entries, nodes)
+	mapping.roles = append(mapping.roles, role)
+}
+
+// Returns the nodes of the mapping, leaving out merged nodes whose key is already taken. A merge keeps
+// all of its own nodes, so that merging an anchor with repeated keys, like the items of a list, keeps them all.
+func (mapping *mergedMapping) resolve() []*YamlNode {
+	taken := make(map[string]bool)
+	for index, nodes := range mapping.entries {
+		for _, node := range nodes {
+			if mapping.roles[index] == _LOCAL_ENTRY && node.Key != "" {
+				taken[node.Key] = true
+			}
+		}
+	}
+
+	resolved := make([]*YamlNode, 0, len(mapping.entries))
+	for index, nodes := range mapping.entries {
+		if mapping.roles[index] != _MERGED_ENTRY {
+			resolved = append(resolved, nodes...)
+			continue
+		}
+
+		merged := make(map[string]bool)
+		for _, node := range nodes {
+			if node.Key != "" && taken[node.Key] {
+				continue
+			}
+
+			merged[node.Key] = true
+			resolved = append(resolved, node)
+		}
+
+		maps.Copy(taken, merged)
+	}
+
+	return resolved
+}
+
+// Determines how a node of the given type takes part in resolving the merge keys of its parent.
+func getMergeRole(lines []sourceLine, nodeType YamlNodeType) mergeRole {
+	if isSequenceEntry(lines[0].text) {
+		return _SEQUENCE_ENTRY
+	}
+
+	if nodeType == _OVERRIDE_YAML_NODE {
+		return _MERGED_ENTRY
+	}
+
+	return _LOCAL_ENTRY
+}
+
+// Parses groups of lines into the children of parent, resolving merge keys.
+func parseEntries(state *parseState, groups [][]sourceLine, parent *YamlNode) ([]*YamlNode, error) {
+	mapping := &mergedMapping{}
+
+	for _, lines := range groups {
+		nodes, role, err := parseNode(state, lines, parent)
+		if err != nil {
+			return nil, err
+		}
+
+		mapping.add(nodes, role)
+	}
+
+	return mapping.resolve(), nil
+}
+
+// Parses a node, along with how it takes part in resolving the merge keys of its parent. It returns an
+// array, because override nodes may return multiple nodes.
+func parseNode(state *parseState, lines []sourceLine, parent *YamlNode) ([]*YamlNode, mergeRole, error) {
+	nodeType, err := determineNodeType(state, lines)
 	if err != nil {
-		return nil, fmt.Errorf("ParseNode failed: %w", err)
+		return nil, 0, fmt.Errorf("ParseNode failed: %w", err)
 	}
+
+	nodes, err := parseNodeOfType(state, lines, parent, nodeType)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	return nodes, getMergeRole(lines, nodeType), nil
+}
+
+// Parses a node whose type is already determined.
+func parseNodeOfType(state *parseState, lines []sourceLine, parent *YamlNode, nodeType YamlNodeType) ([]*YamlNode, error) {
 	switch nodeType {
 	case RAW_YAML_NODE:
-		node, err := parseRawNode(lines, parent, anchorMap)
+		node, err := parseRawNode(state, lines, parent)
 		if err != nil {
 			return nil, fmt.Errorf("ParseRawNode failed: %w", err)
 		}
@@ -451,7 +715,7 @@ func parseNode(lines []string, parent *YamlNode, anchorMap map[string]*YamlNode)
 		}
 		return []*YamlNode{node}, nil
 	case CHILDREN_YAML_NODE:
-		node, err := parseChildrenNode(lines, parent, anchorMap)
+		node, err := parseChildrenNode(state, lines, parent)
 		if err != nil {
 			return nil, fmt.Errorf("ParseChildrenNode failed: %w", err)
 		}
@@ -460,51 +724,70 @@ func parseNode(lines []string, parent *YamlNode, anchorMap map[string]*YamlNode)
 		}
 		return []*YamlNode{node}, nil
 	case _ALIAS_YAML_NODE:
-		node, err := parseAliasNode(lines, parent, anchorMap)
+		nodes, err := parseAliasNode(state, lines, parent)
 		if err != nil {
 			return nil, fmt.Errorf("ParseAliasNode failed: %w", err)
 		}
-		return []*YamlNode{node}, nil
+		return nodes, nil
 	case _OVERRIDE_YAML_NODE:
-		return parseOverrideNode(lines, parent, anchorMap)
+		return parseOverrideNode(state, lines, parent)
+	case _FLOW_YAML_NODE:
+		return parseFlowNode(state, lines, parent)
 	default:
 		return nil, fmt.Errorf("ParseNode failed: unknown node type")
 	}
 }
 
-func getNonEmptyLines(lines []string) []string {
-	nonEmptyLines := make([]string, 0, len(lines))
+// Returns the lines that are not empty or comments, along with their line numbers.
+//
+// The content of block scalars is kept as is, as empty lines and comments are part of it.
+func getNonEmptyLines(lines []string) []sourceLine {
+	nonEmptyLines := make([]sourceLine, 0, len(lines))
 
-	for _, line := range lines {
+	for index := 0; index < len(lines); index++ {
+		line := lines[index]
 		trimmed := strings.Trim(line, " ")
-		if len(trimmed) > 0 && trimmed[0] != '#' {
-			nonEmptyLines = append(nonEmptyLines, line)
+		if len(trimmed) == 0 || trimmed[0] == '#' {
+			continue
+		}
+
+		nonEmptyLines = append(nonEmptyLines, sourceLine{text: line, number: index + 1})
+
+		value, valueIndex := getValue(line)
+
+		// The lines of an unclosed flow collection are continuations of it, whatever their indentation.
+		if len(value) > 0 && isFlowStart(value[0]) {
+			depth := getFlowDepth(line[valueIndex:], 0)
+			for depth > 0 && index+1 < len(lines) {
+				index++
+				if trimmed := strings.TrimLeft(lines[index], " \t"); trimmed == "" || trimmed[0] == '#' {
+					continue
+				}
+				nonEmptyLines = append(nonEmptyLines, sourceLine{text: lines[index], number: index + 1, continued: true})
+				depth = getFlowDepth(lines[index], depth)
+			}
+		}
+
+		if isBlockScalarHeader(value) {
+			indentation := getIndentation(line)
+			for index+1 < len(lines) && (isBlank(lines[index+1]) || getIndentation(lines[index+1]) > indentation) {
+				index++
+				nonEmptyLines = append(nonEmptyLines, sourceLine{text: lines[index], number: index + 1})
+			}
 		}
 	}
 
 	return nonEmptyLines
 }
 
+// Parses lines of yaml into nodes. Use GetYamlDocumentsFromLines for sources with more than one document.
+//
+// Errors in the source can be retrieved as a *ParseError using errors.As.
 func GetYamlNodesFromLines(lines []string) ([]YamlNode, error) {
-	groups, err := collectGroups(getNonEmptyLines(lines))
+	nodes, err := getYamlNodes("", lines)
 	if err != nil {
 		return nil, fmt.Errorf("GetYamlNodesFromLines failed: %w", err)
 	}
 
-	nodes := make([]YamlNode, 0, len(groups))
-
-	anchorMap := make(map[string]*YamlNode)
-
-	for _, topLevelLines := range groups {
-		childNodes, err := parseNode(topLevelLines, nil, anchorMap)
-		if err != nil {
-			return nil, fmt.Errorf("GetYamlNodesFromLines failed: %w", err)
-		}
-
-		for _, childNode := range childNodes {
-			nodes = append(nodes, *childNode)
-		}
-	}
-
 	return nodes, nil
 }
@@ -1,7 +1,9 @@
 package yaml_tmpl_test
 
 import (
+	"errors"
 	"fmt"
+	"strings"
 	"testing"
 
 	"github.com/frodi-karlsson/yaml_tmpl"
@@ -399,6 +401,802 @@ func TestParseOverrideNode(t *testing.T) {
 	}
 }
 
+func TestParseMergeKeys(t *testing.T) {
+	tests := []struct {
+		lines    []string
+		expected []*yaml_tmpl.YamlNode
+	}{
+		{
+			[]string{"base: &base {class: a, id: b}", "div:", "  <<: *base", "  class: c"},
+			[]*yaml_tmpl.YamlNode{
+				{Key: "id", Type: yaml_tmpl.RAW_YAML_NODE, Content: "b"},
+				{Key: "class", Type: yaml_tmpl.RAW_YAML_NODE, Content: "c"},
+			},
+		},
+		{
+			[]string{"a: &a {class: a}", "b: &b {class: b, id: b}", "div:", "  <<: [*a, *b]"},
+			[]*yaml_tmpl.YamlNode{
+				{Key: "class", Type: yaml_tmpl.RAW_YAML_NODE, Content: "a"},
+				{Key: "id", Type: yaml_tmpl.RAW_YAML_NODE, Content: "b"},
+			},
+		},
+		{
+			[]string{"a: &a {class: a}", "b: &b {class: b, id: b}", "div:", "  <<: *b", "  <<: *a"},
+			[]*yaml_tmpl.YamlNode{
+				{Key: "class", Type: yaml_tmpl.RAW_YAML_NODE, Content: "b"},
+				{Key: "id", Type: yaml_tmpl.RAW_YAML_NODE, Content: "b"},
+			},
+		},
+		{
+			[]string{"a: &a {class: a}", "b: &b {class: b, id: b}", "div: {id: c, <<: [*a, *b]}"},
+			[]*yaml_tmpl.YamlNode{
+				{Key: "id", Type: yaml_tmpl.RAW_YAML_NODE, Content: "c"},
+				{Key: "class", Type: yaml_tmpl.RAW_YAML_NODE, Content: "a"},
+			},
+		},
+		{
+			[]string{"items: &items", "  - li: a", "  - li: b", "div:", "  - <<: *items", "  - li: c"},
+			[]*yaml_tmpl.YamlNode{
+				{Key: "li", Type: yaml_tmpl.RAW_YAML_NODE, Content: "a"},
+				{Key: "li", Type: yaml_tmpl.RAW_YAML_NODE, Content: "b"},
+				{Key: "li", Type: yaml_tmpl.RAW_YAML_NODE, Content: "c"},
+			},
+		},
+	}
+
+	for _, test := range tests {
+		nodes, err := yaml_tmpl.GetYamlNodesFromLines(test.lines)
+		if err != nil {
+			t.Errorf("Unexpected error for %v: %v", test.lines, err)
+			continue
+		}
+
+		if len(nodes) != 1 {
+			t.Errorf("Expected 1 node for %v, got %d", test.lines, len(nodes))
+			continue
+		}
+
+		res, msg := expectYamlNodeToEqual(t, nodes[0], yaml_tmpl.YamlNode{
+			Key:      "div",
+			Type:     yaml_tmpl.CHILDREN_YAML_NODE,
+			Children: test.expected,
+		})
+		if !res {
+			t.Errorf("%v: %s", test.lines, msg)
+		}
+	}
+}
+
+func TestParseInvalidMergeKeys(t *testing.T) {
+	invalid := [][]string{
+		{"a: &a {class: a}", "div:", "  <<: [*a, b]"},
+		{"a: &a {class: a}", "div:", "  <<: [*a, *missing]"},
+		{"a: &a {class: a}", "div:", "  <<: [*a"},
+		{"a: &a {class: a}", "div: {<<: [*a *a]}"},
+		{"a: &a a", "div:", "  <<: [*a]"},
+	}
+
+	for _, lines := range invalid {
+		_, err := yaml_tmpl.GetYamlNodesFromLines(lines)
+
+		var parseError *yaml_tmpl.ParseError
+		if !errors.As(err, &parseError) {
+			t.Errorf("Expected a ParseError for %v, got %v", lines, err)
+		}
+	}
+}
+
+// Checks that the children of a node point at it, all the way down.
+func expectParentsToBeCorrect(t *testing.T, node *yaml_tmpl.YamlNode) {
+	t.Helper()
+
+	for _, child := range node.Children {
+		if child.Parent != node {
+			t.Errorf("Expected the parent of %s to be %s", child.Key, node.Key)
+		}
+		expectParentsToBeCorrect(t, child)
+	}
+}
+
+func TestYamlNodeClone(t *testing.T) {
+	nodes, err := yaml_tmpl.GetYamlNodesFromLines([]string{
+		"div:",
+		"  children:",
+		"    - p: \"value\"",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	node := nodes[0].Children[0]
+	clone := node.Clone()
+
+	if clone.Parent != node.Parent {
+		t.Error("Expected the clone to have the same parent as the node")
+	}
+	expectParentsToBeCorrect(t, clone)
+
+	clone.Children[0].Content = "changed"
+	clone.Children = append(clone.Children, &yaml_tmpl.YamlNode{Key: "span"})
+
+	res, msg := expectYamlNodeToEqual(t, *node, yaml_tmpl.YamlNode{
+		Key:  "children",
+		Type: yaml_tmpl.CHILDREN_YAML_NODE,
+		Children: []*yaml_tmpl.YamlNode{
+			{Key: "p", Type: yaml_tmpl.RAW_YAML_NODE, Content: "value"},
+		},
+	})
+	if !res {
+		t.Errorf("Changing the clone changed the node: %s", msg)
+	}
+}
+
+func TestParseAliasParents(t *testing.T) {
+	nodes, err := yaml_tmpl.GetYamlNodesFromLines([]string{
+		"card: &card",
+		"  div:",
+		"    children:",
+		"      - p: \"value\"",
+		"first: *card",
+		"second:",
+		"  <<: *card",
+		"third: {<<: [*card]}",
+		"list:",
+		"  - *card",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(nodes) != 4 {
+		t.Fatalf("Expected 4 nodes, got %d", len(nodes))
+	}
+
+	paragraphs := make(map[*yaml_tmpl.YamlNode]bool)
+	var collect func(node *yaml_tmpl.YamlNode)
+	collect = func(node *yaml_tmpl.YamlNode) {
+		if node.Key == "p" {
+			paragraphs[node] = true
+		}
+		for _, child := range node.Children {
+			collect(child)
+		}
+	}
+
+	for index := range nodes {
+		if nodes[index].Parent != nil {
+			t.Errorf("Expected %s to have no parent", nodes[index].Key)
+		}
+		expectParentsToBeCorrect(t, &nodes[index])
+		collect(&nodes[index])
+	}
+
+	if len(paragraphs) != 4 {
+		t.Errorf("Expected every alias to have its own copy of the anchor, got %d copies", len(paragraphs))
+	}
+}
+
+func TestParseKeylessAliases(t *testing.T) {
+	nodes, err := yaml_tmpl.GetYamlNodesFromLines([]string{
+		"x: &x {p: hi}",
+		"text: &text hello",
+		"div:",
+		"  children:",
+		"    - *x",
+		"    - *text",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(nodes) != 1 {
+		t.Fatalf("Expected 1 node, got %d", len(nodes))
+	}
+
+	res, msg := expectYamlNodeToEqual(t, nodes[0], yaml_tmpl.YamlNode{
+		Key:  "div",
+		Type: yaml_tmpl.CHILDREN_YAML_NODE,
+		Children: []*yaml_tmpl.YamlNode{
+			{
+				Key:  "children",
+				Type: yaml_tmpl.CHILDREN_YAML_NODE,
+				Children: []*yaml_tmpl.YamlNode{
+					{Key: "p", Type: yaml_tmpl.RAW_YAML_NODE, Content: "hi"},
+					{Type: yaml_tmpl.RAW_YAML_NODE, Content: "hello"},
+				},
+			},
+		},
+	})
+	if !res {
+		t.Error(msg)
+	}
+
+	expectParentsToBeCorrect(t, &nodes[0])
+}
+
+func TestParseLiteralBlockScalar(t *testing.T) {
+	nodes, err := yaml_tmpl.GetYamlNodesFromLines([]string{
+		"script: |",
+		"  if (a) {",
+		"    # Not a comment",
+		"",
+		"    b(\"c\")",
+		"  }",
+		"",
+		"p: \"after\"",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(nodes) != 2 {
+		t.Fatalf("Expected 2 nodes, got %d", len(nodes))
+	}
+
+	res, msg := expectYamlNodeToEqual(t, nodes[0], yaml_tmpl.YamlNode{
+		Key:     "script",
+		Type:    yaml_tmpl.RAW_YAML_NODE,
+		Content: "if (a) {\n  # Not a comment\n\n  b(\"c\")\n}\n",
+	})
+	if !res {
+		t.Error(msg)
+	}
+}
+
+func TestParseFoldedBlockScalar(t *testing.T) {
+	nodes, err := yaml_tmpl.GetYamlNodesFromLines([]string{
+		"div:",
+		"  children:",
+		"    - p: >-",
+		"        A long paragraph",
+		"        over several lines.",
+		"",
+		"        A new paragraph.",
+		"          Indented lines keep",
+		"          their line breaks.",
+		"    - p: \"after\"",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	paragraph := nodes[0].Children[0].Children[0]
+	expected := "A long paragraph over several lines.\nA new paragraph.\n  Indented lines keep\n  their line breaks."
+	if paragraph.Content != expected {
+		t.Errorf("Expected %q, got %q", expected, paragraph.Content)
+	}
+
+	if len(nodes[0].Children[0].Children) != 2 {
+		t.Errorf("Expected 2 children, got %d", len(nodes[0].Children[0].Children))
+	}
+}
+
+func TestParseBlockScalarIndicators(t *testing.T) {
+	nodes, err := yaml_tmpl.GetYamlNodesFromLines([]string{
+		"strip: |-",
+		"  text",
+		"",
+		"keep: |+ # comments are allowed after the header",
+		"  text",
+		"",
+		"",
+		"indented: |2",
+		"    leading spaces",
+		"  text",
+		"anchored: &text >",
+		"  folded",
+		"  text",
+		"alias: *text",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := []string{"text", "text\n\n\n", "  leading spaces\ntext\n"}
+	for index, content := range expected {
+		if nodes[index].Content != content {
+			t.Errorf("Expected %q for %s, got %q", content, nodes[index].Key, nodes[index].Content)
+		}
+	}
+
+	alias := nodes[3].Children[0]
+	if alias.Content != "folded text\n" {
+		t.Errorf("Expected %q, got %q", "folded text\n", alias.Content)
+	}
+}
+
+func TestParseBlockScalarIndicatorsInSequences(t *testing.T) {
+	nodes, err := yaml_tmpl.GetYamlNodesFromLines([]string{
+		"div:",
+		"  children:",
+		"    - p: |2",
+		"          leading spaces",
+		"        text",
+		"    - |1",
+		"       leading space",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	res, msg := expectYamlNodeToEqual(t, nodes[0], yaml_tmpl.YamlNode{
+		Key:  "div",
+		Type: yaml_tmpl.CHILDREN_YAML_NODE,
+		Children: []*yaml_tmpl.YamlNode{
+			{
+				Key:  "children",
+				Type: yaml_tmpl.CHILDREN_YAML_NODE,
+				Children: []*yaml_tmpl.YamlNode{
+					{Key: "p", Type: yaml_tmpl.RAW_YAML_NODE, Content: "  leading spaces\ntext\n"},
+					{Type: yaml_tmpl.RAW_YAML_NODE, Content: "  leading space\n"},
+				},
+			},
+		},
+	})
+	if !res {
+		t.Error(msg)
+	}
+}
+
+func TestParseInvalidBlockScalarHeader(t *testing.T) {
+	_, err := yaml_tmpl.GetYamlNodesFromLines([]string{
+		"p: |x",
+		"  text",
+	})
+
+	var parseError *yaml_tmpl.ParseError
+	if !errors.As(err, &parseError) {
+		t.Fatalf("Expected a ParseError, got %v", err)
+	}
+
+	if parseError.Position.Line != 1 || parseError.Position.Column != 5 {
+		t.Errorf("Unexpected position: %+v", parseError.Position)
+	}
+}
+
+func TestParsePlainScalars(t *testing.T) {
+	nodes, err := yaml_tmpl.GetYamlNodesFromLines([]string{
+		"p: hello world",
+		"a:",
+		"  class: title # a comment",
+		"  href: https://example.com/#anchor?a=b&c=d",
+		"  title: 2 * 3 = 6",
+		"  \"data-quoted key\": 'it''s \"quoted\"'",
+		"  alt: \"escapes\\tand 'quotes'\\u0021\"",
+		"  hidden:",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	res, msg := expectYamlNodeToEqual(t, nodes[0], yaml_tmpl.YamlNode{
+		Key:     "p",
+		Type:    yaml_tmpl.RAW_YAML_NODE,
+		Content: "hello world",
+	})
+	if !res {
+		t.Error(msg)
+	}
+
+	res, msg = expectYamlNodeToEqual(t, nodes[1], yaml_tmpl.YamlNode{
+		Key:  "a",
+		Type: yaml_tmpl.CHILDREN_YAML_NODE,
+		Children: []*yaml_tmpl.YamlNode{
+			{Key: "class", Type: yaml_tmpl.RAW_YAML_NODE, Content: "title"},
+			{Key: "href", Type: yaml_tmpl.RAW_YAML_NODE, Content: "https://example.com/#anchor?a=b&c=d"},
+			{Key: "title", Type: yaml_tmpl.RAW_YAML_NODE, Content: "2 * 3 = 6"},
+			{Key: "data-quoted key", Type: yaml_tmpl.RAW_YAML_NODE, Content: "it's \"quoted\""},
+			{Key: "alt", Type: yaml_tmpl.RAW_YAML_NODE, Content: "escapes\tand 'quotes'!"},
+			{Key: "hidden", Type: yaml_tmpl.RAW_YAML_NODE, Content: ""},
+		},
+	})
+	if !res {
+		t.Error(msg)
+	}
+}
+
+func TestParseInvalidPlainScalars(t *testing.T) {
+	invalid := [][]string{
+		{"p: a: b"},
+		{"p: @mention"},
+		{"p: \"quoted\" and more"},
+		{"p: hello", "  class: title"},
+		{"just text"},
+	}
+
+	for _, lines := range invalid {
+		_, err := yaml_tmpl.GetYamlNodesFromLines(lines)
+
+		var parseError *yaml_tmpl.ParseError
+		if !errors.As(err, &parseError) {
+			t.Errorf("Expected a ParseError for %v, got %v", lines, err)
+		}
+	}
+}
+
+var FLOW_MAPPING_NODE = []string{
+	"img: {src: \"a.png\", alt: A picture, hidden}",
+}
+
+var FLOW_SEQUENCE_NODE = []string{
+	"ul:",
+	"  children: [",
+	"    li: first, # comments are allowed",
+	"    {li: second, li: 'third'},",
+	"    \"text\", [nested, list]",
+	"  ]",
+}
+
+var BLOCK_SEQUENCE_NODE = []string{
+	"ul:",
+	"  children:",
+	"    - li: first",
+	"    - li: second",
+	"    - li: 'third'",
+	"    - \"text\"",
+	"    -",
+	"      - nested",
+	"      - list",
+}
+
+func TestParseFlowMapping(t *testing.T) {
+	nodes, err := yaml_tmpl.GetYamlNodesFromLines(FLOW_MAPPING_NODE)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	res, msg := expectYamlNodeToEqual(t, nodes[0], yaml_tmpl.YamlNode{
+		Key:  "img",
+		Type: yaml_tmpl.CHILDREN_YAML_NODE,
+		Children: []*yaml_tmpl.YamlNode{
+			{Key: "src", Type: yaml_tmpl.RAW_YAML_NODE, Content: "a.png"},
+			{Key: "alt", Type: yaml_tmpl.RAW_YAML_NODE, Content: "A picture"},
+			{Key: "hidden", Type: yaml_tmpl.RAW_YAML_NODE, Content: ""},
+		},
+	})
+	if !res {
+		t.Error(msg)
+	}
+
+	for _, child := range nodes[0].Children {
+		if child.Parent == nil || child.Parent.Key != "img" {
+			t.Errorf("Expected the parent of %s to be img", child.Key)
+		}
+	}
+}
+
+func TestParseFlowSequenceMatchesBlockSequence(t *testing.T) {
+	flowNodes, err := yaml_tmpl.GetYamlNodesFromLines(FLOW_SEQUENCE_NODE)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	blockNodes, err := yaml_tmpl.GetYamlNodesFromLines(BLOCK_SEQUENCE_NODE)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := yaml_tmpl.YamlNode{
+		Key:  "ul",
+		Type: yaml_tmpl.CHILDREN_YAML_NODE,
+		Children: []*yaml_tmpl.YamlNode{
+			{
+				Key:  "children",
+				Type: yaml_tmpl.CHILDREN_YAML_NODE,
+				Children: []*yaml_tmpl.YamlNode{
+					{Key: "li", Type: yaml_tmpl.RAW_YAML_NODE, Content: "first"},
+					{Key: "li", Type: yaml_tmpl.RAW_YAML_NODE, Content: "second"},
+					{Key: "li", Type: yaml_tmpl.RAW_YAML_NODE, Content: "third"},
+					{Key: "", Type: yaml_tmpl.RAW_YAML_NODE, Content: "text"},
+					{
+						Key:  "",
+						Type: yaml_tmpl.CHILDREN_YAML_NODE,
+						Children: []*yaml_tmpl.YamlNode{
+							{Key: "", Type: yaml_tmpl.RAW_YAML_NODE, Content: "nested"},
+							{Key: "", Type: yaml_tmpl.RAW_YAML_NODE, Content: "list"},
+						},
+					},
+				},
+			},
+		},
+	}
+
+	res, msg := expectYamlNodeToEqual(t, flowNodes[0], expected)
+	if !res {
+		t.Errorf("Flow: %s", msg)
+	}
+
+	res, msg = expectYamlNodeToEqual(t, blockNodes[0], expected)
+	if !res {
+		t.Errorf("Block: %s", msg)
+	}
+}
+
+func TestParseFlowAliases(t *testing.T) {
+	nodes, err := yaml_tmpl.GetYamlNodesFromLines([]string{
+		"defaults: &defaults {class: card, id: &id main}",
+		"div: {<<: *defaults, title: Card}",
+		"section: [*id]",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(nodes) != 2 {
+		t.Fatalf("Expected 2 nodes, got %d", len(nodes))
+	}
+
+	res, msg := expectYamlNodeToEqual(t, nodes[0], yaml_tmpl.YamlNode{
+		Key:  "div",
+		Type: yaml_tmpl.CHILDREN_YAML_NODE,
+		Children: []*yaml_tmpl.YamlNode{
+			{Key: "class", Type: yaml_tmpl.RAW_YAML_NODE, Content: "card"},
+			{Key: "title", Type: yaml_tmpl.RAW_YAML_NODE, Content: "Card"},
+		},
+	})
+	if !res {
+		t.Error(msg)
+	}
+
+	res, msg = expectYamlNodeToEqual(t, nodes[1], yaml_tmpl.YamlNode{
+		Key:  "section",
+		Type: yaml_tmpl.CHILDREN_YAML_NODE,
+		Children: []*yaml_tmpl.YamlNode{
+			{Type: yaml_tmpl.RAW_YAML_NODE, Content: "main"},
+		},
+	})
+	if !res {
+		t.Error(msg)
+	}
+}
+
+func TestParseInvalidFlowCollections(t *testing.T) {
+	invalid := [][]string{
+		{"img: {src: a.png"},
+		{"ul: [a b, c] d"},
+		{"ul: [a, \"b]"},
+		{"ul: {a: b] "},
+	}
+
+	for _, lines := range invalid {
+		_, err := yaml_tmpl.GetYamlNodesFromLines(lines)
+
+		var parseError *yaml_tmpl.ParseError
+		if !errors.As(err, &parseError) {
+			t.Errorf("Expected a ParseError for %v, got %v", lines, err)
+		}
+	}
+}
+
+func TestParsePositions(t *testing.T) {
+	nodes, err := yaml_tmpl.GetYamlNodesFromLines(DOCUMENT_NODE)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	head := nodes[0]
+	if head.Position != (yaml_tmpl.Position{Line: 1, Column: 1, EndLine: 7}) {
+		t.Errorf("Unexpected position for head: %+v", head.Position)
+	}
+
+	link := head.Children[0].Children[1]
+	if link.Position != (yaml_tmpl.Position{Line: 4, Column: 7, EndLine: 7}) {
+		t.Errorf("Unexpected position for link: %+v", link.Position)
+	}
+
+	href := link.Children[2]
+	if href.Position != (yaml_tmpl.Position{Line: 7, Column: 7, EndLine: 7}) {
+		t.Errorf("Unexpected position for href: %+v", href.Position)
+	}
+}
+
+func TestParseError(t *testing.T) {
+	_, err := yaml_tmpl.GetYamlNodesFromLines([]string{
+		"head:",
+		"",
+		"  children:",
+		"    - title: \"Unclosed",
+	})
+	if err == nil {
+		t.Fatal("Expected an error")
+	}
+
+	var parseError *yaml_tmpl.ParseError
+	if !errors.As(err, &parseError) {
+		t.Fatalf("Expected a ParseError, got %v", err)
+	}
+
+	if parseError.Position.Line != 4 || parseError.Position.Column != 14 {
+		t.Errorf("Unexpected position: %+v", parseError.Position)
+	}
+
+	if parseError.Message != "missing closing quote" {
+		t.Errorf("Unexpected message: %s", parseError.Message)
+	}
+
+	expectedExcerpt := " 4 |     - title: \"Unclosed\n   |              ^"
+	if parseError.Excerpt != expectedExcerpt {
+		t.Errorf("Expected excerpt:\n%s\ngot:\n%s", expectedExcerpt, parseError.Excerpt)
+	}
+}
+
+func TestParseErrorForUndefinedAnchor(t *testing.T) {
+	_, err := yaml_tmpl.GetYamlNodesFromLines([]string{
+		"tag: *missing",
+	})
+
+	var parseError *yaml_tmpl.ParseError
+	if !errors.As(err, &parseError) {
+		t.Fatalf("Expected a ParseError, got %v", err)
+	}
+
+	if parseError.Position.Line != 1 || parseError.Position.Column != 6 {
+		t.Errorf("Unexpected position: %+v", parseError.Position)
+	}
+}
+
+var MULTI_DOCUMENT_STREAM = []string{
+	"%YAML 1.2",
+	"---",
+	"p: Hello",
+	"...",
+	"--- # about",
+	"p: About",
+	"div: |",
+	"  ---",
+	"---",
+	"span: [a,",
+	"---, b]",
+}
+
+func TestParseDocuments(t *testing.T) {
+	documents, err := yaml_tmpl.GetYamlDocumentsFromLines(MULTI_DOCUMENT_STREAM)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(documents) != 3 {
+		t.Fatalf("Expected 3 documents, got %d", len(documents))
+	}
+
+	expected := [][]yaml_tmpl.YamlNode{
+		{
+			{Key: "p", Type: yaml_tmpl.RAW_YAML_NODE, Content: "Hello"},
+		},
+		{
+			{Key: "p", Type: yaml_tmpl.RAW_YAML_NODE, Content: "About"},
+			{Key: "div", Type: yaml_tmpl.RAW_YAML_NODE, Content: "---\n"},
+		},
+		{
+			{Key: "span", Type: yaml_tmpl.CHILDREN_YAML_NODE, Children: []*yaml_tmpl.YamlNode{
+				{Type: yaml_tmpl.RAW_YAML_NODE, Content: "a"},
+				{Type: yaml_tmpl.RAW_YAML_NODE, Content: "---"},
+				{Type: yaml_tmpl.RAW_YAML_NODE, Content: "b"},
+			}},
+		},
+	}
+
+	for index, nodes := range documents {
+		if len(nodes) != len(expected[index]) {
+			t.Fatalf("Expected %d nodes in document %d, got %d", len(expected[index]), index, len(nodes))
+		}
+
+		for nodeIndex, node := range nodes {
+			res, msg := expectYamlNodeToEqual(t, node, expected[index][nodeIndex])
+			if !res {
+				t.Error(msg)
+			}
+		}
+	}
+
+	// Line numbers are counted from the start of the stream.
+	if documents[1][0].Position.Line != 6 {
+		t.Errorf("Unexpected position: %+v", documents[1][0].Position)
+	}
+}
+
+func TestParseDocumentsEmpty(t *testing.T) {
+	documents, err := yaml_tmpl.GetYamlDocumentsFromLines([]string{"---", "---", "p: a", "..."})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(documents) != 2 || len(documents[0]) != 0 || len(documents[1]) != 1 {
+		t.Errorf("Unexpected documents: %v", documents)
+	}
+}
+
+func TestParseDocumentMarkerErrors(t *testing.T) {
+	tests := []struct {
+		lines   []string
+		message string
+	}{
+		{[]string{"--- p: a"}, `1:5: only a comment can follow "---" on the same line`},
+		{[]string{"p: a", "... p: b"}, `2:5: only a comment can follow "..." on the same line`},
+	}
+
+	for _, test := range tests {
+		_, err := yaml_tmpl.GetYamlDocumentsFromLines(test.lines)
+		if err == nil || !strings.Contains(err.Error(), test.message) {
+			t.Errorf("Expected an error containing %q for %v, got %v", test.message, test.lines, err)
+		}
+	}
+}
+
+func TestParseDocumentsAnchorsAreLocal(t *testing.T) {
+	_, err := yaml_tmpl.GetYamlDocumentsFromLines([]string{"p: &a Hello", "---", "div: *a"})
+
+	var parseError *yaml_tmpl.ParseError
+	if !errors.As(err, &parseError) {
+		t.Fatalf("Expected a ParseError, got %v", err)
+	}
+
+	if parseError.Position.Line != 3 {
+		t.Errorf("Unexpected position: %+v", parseError.Position)
+	}
+}
+
+func TestParseSingleDocument(t *testing.T) {
+	nodes, err := yaml_tmpl.GetYamlNodesFromLines([]string{"---", "p: Hello", "..."})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(nodes) != 1 || nodes[0].Content != "Hello" {
+		t.Errorf("Unexpected nodes: %v", nodes)
+	}
+
+	_, err = yaml_tmpl.GetYamlNodesFromLines([]string{"p: Hello", "---", "p: World"})
+
+	var parseError *yaml_tmpl.ParseError
+	if !errors.As(err, &parseError) {
+		t.Fatalf("Expected a ParseError, got %v", err)
+	}
+
+	if parseError.Position.Line != 2 || parseError.Message != "expected a single document, found 2" {
+		t.Errorf("Unexpected error: %v", parseError)
+	}
+}
+
+func TestParseInvalidDocumentMarker(t *testing.T) {
+	_, err := yaml_tmpl.GetYamlDocumentsFromLines([]string{"--- p: Hello"})
+
+	var parseError *yaml_tmpl.ParseError
+	if !errors.As(err, &parseError) {
+		t.Fatalf("Expected a ParseError, got %v", err)
+	}
+
+	if parseError.Position.Line != 1 || parseError.Position.Column != 5 {
+		t.Errorf("Unexpected position: %+v", parseError.Position)
+	}
+}
+
+func TestParsePlainScalarStyle(t *testing.T) {
+	nodes, err := yaml_tmpl.GetYamlNodesFromLines([]string{
+		"input:",
+		"  disabled: true",
+		"  value: \"true\"",
+		"  hidden:",
+		"  title: |",
+		"    true",
+		"  flow: [true, 'true']",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	children := nodes[0].Children
+	flow := children[4].Children
+	plain := []bool{children[0].Plain, children[1].Plain, children[2].Plain, children[3].Plain, flow[0].Plain, flow[1].Plain}
+	expected := []bool{true, false, true, false, true, false}
+
+	for index := range expected {
+		if plain[index] != expected[index] {
+			t.Errorf("Expected Plain to be %t for value %d, got %t", expected[index], index, plain[index])
+		}
+	}
+}
+
 func expectYamlNodeToEqual(t *testing.T, node yaml_tmpl.YamlNode, expected yaml_tmpl.YamlNode) (bool, string) {
 	return _expectYamlNodeToEqual(t, node, expected, "")
 }
@@ -0,0 +1,96 @@
+package yaml_tmpl
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// A position in a yaml source.
+type Position struct {
+	// The name of the source. Empty if it has none, such as when using GetYamlNodesFromLines.
+	File string
+	// The 1-based line the node is defined on.
+	Line int
+	// The 1-based column the node's key starts at.
+	Column int
+	// The 1-based line of the last line belonging to the node.
+	EndLine int
+}
+
+// Formats the position as file:line:column, leaving out the file if there is none.
+func (position Position) String() string {
+	location := strconv.Itoa(position.Line) + ":" + strconv.Itoa(position.Column)
+	if position.File == "" {
+		return location
+	}
+
+	return position.File + ":" + location
+}
+
+// An error in a yaml source. Use errors.As to get it from errors returned by the parser.
+type ParseError struct {
+	Position Position
+	Message  string
+	// The source line the error occurred on, with a caret below it pointing at the column.
+	Excerpt string
+}
+
+func (err *ParseError) Error() string {
+	if err.Excerpt == "" {
+		return err.Position.String() + ": " + err.Message
+	}
+
+	return err.Position.String() + ": " + err.Message + "\n" + err.Excerpt
+}
+
+// A line of yaml source, along with its 1-based line number.
+type sourceLine struct {
+	text   string
+	number int
+	// True if the line continues a flow collection from the lines before it.
+	// Its indentation doesn't matter, so that closing brackets can be at any indentation.
+	continued bool
+}
+
+// Creates an excerpt of a line with a caret pointing at the 1-based column.
+func createExcerpt(line sourceLine, column int) string {
+	gutter := strconv.Itoa(line.number)
+	padding := strings.Repeat(" ", len(gutter))
+
+	// Keep tabs in the caret line, so that the caret lines up with the source line.
+	caretPrefix := make([]rune, 0, column)
+	for index, char := range line.text {
+		if index >= column-1 {
+			break
+		}
+		if char == '\t' {
+			caretPrefix = append(caretPrefix, '\t')
+		} else {
+			caretPrefix = append(caretPrefix, ' ')
+		}
+	}
+
+	return fmt.Sprintf(" %s | %s\n %s | %s^", gutter, line.text, padding, string(caretPrefix))
+}
+
+// Returns the 1-based column the key of a line starts at, skipping indentation and a sequence dash.
+func keyColumn(line string) int {
+	index := 0
+	for index < len(line) && (line[index] == ' ' || line[index] == '\t') {
+		index++
+	}
+
+	if line[index:] == "-" {
+		return index + 2
+	}
+
+	if strings.HasPrefix(line[index:], "- ") {
+		index += 2
+		for index < len(line) && line[index] == ' ' {
+			index++
+		}
+	}
+
+	return index + 1
+}
@@ -0,0 +1,342 @@
+package yaml_tmpl
+
+import (
+	"fmt"
+	"io"
+	"strings"
+)
+
+// How HTML is laid out when written.
+type outputFormat int
+
+const (
+	// Everything on a single line, exactly as transpiled.
+	_COMPACT_FORMAT outputFormat = iota
+	// Block-level elements on lines of their own, indented by their depth.
+	_INDENTED_FORMAT
+	// As small as possible without changing how the page renders.
+	_MINIFIED_FORMAT
+)
+
+// Elements that are laid out as blocks, so whitespace around them doesn't change how a page renders.
+// Elements that aren't listed, including custom elements, are treated as inline.
+var _BLOCK_ELEMENTS = map[string]bool{
+	"address":    true,
+	"article":    true,
+	"aside":      true,
+	"base":       true,
+	"blockquote": true,
+	"body":       true,
+	"caption":    true,
+	"col":        true,
+	"colgroup":   true,
+	"dd":         true,
+	"details":    true,
+	"dialog":     true,
+	"div":        true,
+	"dl":         true,
+	"dt":         true,
+	"fieldset":   true,
+	"figcaption": true,
+	"figure":     true,
+	"footer":     true,
+	"form":       true,
+	"h1":         true,
+	"h2":         true,
+	"h3":         true,
+	"h4":         true,
+	"h5":         true,
+	"h6":         true,
+	"head":       true,
+	"header":     true,
+	"hgroup":     true,
+	"hr":         true,
+	"html":       true,
+	"li":         true,
+	"link":       true,
+	"main":       true,
+	"meta":       true,
+	"nav":        true,
+	"noscript":   true,
+	"ol":         true,
+	"optgroup":   true,
+	"option":     true,
+	"p":          true,
+	"pre":        true,
+	"script":     true,
+	"section":    true,
+	"style":      true,
+	"summary":    true,
+	"table":      true,
+	"tbody":      true,
+	"td":         true,
+	"template":   true,
+	"tfoot":      true,
+	"th":         true,
+	"thead":      true,
+	"title":      true,
+	"tr":         true,
+	"ul":         true,
+}
+
+// Elements whose whitespace is significant. Their content is always written as is.
+var _PREFORMATTED_ELEMENTS = map[string]bool{
+	"pre":      true,
+	"script":   true,
+	"style":    true,
+	"textarea": true,
+}
+
+// Writes strings to an io.Writer, keeping track of the number of bytes written.
+// After the first error, nothing more is written.
+type htmlWriter struct {
+	w       io.Writer
+	written int64
+	err     error
+	format  outputFormat
+	// Written once per level of depth at the start of each line in the indented format.
+	indent string
+	// True while writing the content of an element whose whitespace is significant.
+	preformatted bool
+}
+
+func (writer *htmlWriter) writeString(content string) {
+	if writer.err != nil {
+		return
+	}
+
+	written, err := io.WriteString(writer.w, content)
+	writer.written += int64(written)
+	writer.err = err
+}
+
+// Writes content through a replacer, without creating an intermediate string.
+func (writer *htmlWriter) writeEscaped(replacer *strings.Replacer, content string) {
+	if writer.err != nil {
+		return
+	}
+
+	written, err := replacer.WriteString(writer.w, content)
+	writer.written += int64(written)
+	writer.err = err
+}
+
+// Starts a new line, indented to depth.
+func (writer *htmlWriter) writeLine(depth int) {
+	writer.writeString("\n")
+	for level := 0; level < depth; level++ {
+		writer.writeString(writer.indent)
+	}
+}
+
+// Writes the content of a raw node, escaped for the element it's a child of.
+func (node *HtmlNode) writeContent(writer *htmlWriter, parentTag string) {
+	content := node.Content
+	minify := writer.format == _MINIFIED_FORMAT && !writer.preformatted
+
+	if node.Raw {
+		if minify {
+			content = minifyRawHTML(content)
+		}
+		writer.writeString(content)
+		return
+	}
+
+	if minify {
+		content = collapseWhitespace(content)
+	}
+	writeEscapedContent(writer, parentTag, content)
+}
+
+// Determines whether a tag is a block-level element with content, and all of it is block-level elements.
+func (node *HtmlNode) hasOnlyBlockContent() bool {
+	if !_BLOCK_ELEMENTS[strings.ToLower(node.Tag)] {
+		return false
+	}
+
+	hasContent := false
+
+	for _, child := range node.Children {
+		if child.Type == ATTRIBUTE_HTML_NODE {
+			continue
+		}
+
+		if child.Type != TAG_HTML_NODE || !_BLOCK_ELEMENTS[strings.ToLower(child.Tag)] {
+			return false
+		}
+
+		hasContent = true
+	}
+
+	return hasContent
+}
+
+// Writes the children of a tag that aren't attributes.
+//
+// In the indented format, the children of a block-level element get a line each if they are all block-level
+// elements too. Anything else is written as is, since whitespace between inline content could show up on the page.
+func (node *HtmlNode) writeChildren(writer *htmlWriter, depth int) {
+	preformatted := writer.preformatted
+	if _PREFORMATTED_ELEMENTS[strings.ToLower(node.Tag)] {
+		writer.preformatted = true
+	}
+
+	breakLines := writer.format == _INDENTED_FORMAT && !writer.preformatted && node.hasOnlyBlockContent()
+	childDepth := depth
+	if breakLines {
+		childDepth++
+	}
+
+	for index, child := range node.Children {
+		if child.Type == ATTRIBUTE_HTML_NODE {
+			continue
+		}
+
+		if breakLines {
+			writer.writeLine(childDepth)
+		}
+
+		if child.Type == RAW_HTML_NODE {
+			child.writeContent(writer, node.Tag)
+		} else {
+			child.write(writer, childDepth, firstContent(node.Children[index+1:]))
+		}
+	}
+
+	if breakLines {
+		writer.writeLine(depth)
+	}
+
+	writer.preformatted = preformatted
+}
+
+// Returns the first of nodes that isn't an attribute, or nil if there is none.
+func firstContent(nodes []*HtmlNode) *HtmlNode {
+	for _, node := range nodes {
+		if node.Type != ATTRIBUTE_HTML_NODE {
+			return node
+		}
+	}
+
+	return nil
+}
+
+// Writes the node at the given depth, which is only used for indentation. Next is the node that
+// follows it, which is only used to leave out end tags when minifying.
+func (node *HtmlNode) write(writer *htmlWriter, depth int, next *HtmlNode) {
+	switch node.Type {
+	case RAW_HTML_NODE:
+		parentTag := ""
+		if node.Parent != nil {
+			parentTag = node.Parent.Tag
+		}
+		node.writeContent(writer, parentTag)
+	case TAG_HTML_NODE:
+		writer.writeString("<")
+		writer.writeString(node.Tag)
+
+		for _, child := range node.Children {
+			if child.Type == ATTRIBUTE_HTML_NODE {
+				writer.writeString(" ")
+				child.write(writer, depth, nil)
+			}
+		}
+
+		writer.writeString(">")
+
+		if isVoidElement(node.Tag) {
+			return
+		}
+
+		node.writeChildren(writer, depth)
+
+		if writer.format == _MINIFIED_FORMAT && node.canOmitEndTag(next) {
+			return
+		}
+
+		writer.writeString("</")
+		writer.writeString(node.Tag)
+		writer.writeString(">")
+	case ATTRIBUTE_HTML_NODE:
+		if node.Boolean {
+			writer.writeString(node.Attribute)
+			return
+		}
+
+		if writer.format == _MINIFIED_FORMAT {
+			writeMinifiedAttribute(writer, node.Attribute, node.Content)
+			return
+		}
+
+		writer.writeString(node.Attribute)
+		writer.writeString("=\"")
+		writeEscapedAttribute(writer, node.Attribute, node.Content)
+		writer.writeString("\"")
+	}
+}
+
+// Writes the node as HTML to w, without building the document as a string first.
+// It implements io.WriterTo.
+//
+// Text content and attribute values are escaped according to their context, except for `raw:` nodes.
+func (node *HtmlNode) WriteTo(w io.Writer) (int64, error) {
+	writer := &htmlWriter{w: w}
+	node.write(writer, 0, nil)
+
+	return writer.written, writer.err
+}
+
+// Writes the node as indented HTML to w, with block-level elements on lines of their own and
+// indent repeated once per level of nesting.
+//
+// Whitespace is only added where it can't change how the page renders. The content of inline
+// elements and of whitespace sensitive elements such as pre and textarea is written as is.
+func (node *HtmlNode) WriteIndentedTo(w io.Writer, indent string) (int64, error) {
+	writer := &htmlWriter{w: w, format: _INDENTED_FORMAT, indent: indent}
+	node.write(writer, 0, nil)
+
+	return writer.written, writer.err
+}
+
+// Converts an HTML node to a string.
+//
+// Text content and attribute values are escaped according to their context, except for `raw:` nodes.
+func (node *HtmlNode) String() string {
+	var builder strings.Builder
+	node.WriteTo(&builder)
+
+	return builder.String()
+}
+
+// Writes the node as minified HTML to w. The output renders the same as that of WriteTo, but
+// whitespace in text is collapsed, comments in `raw:` content are removed, attribute values are only
+// quoted where needed and end tags that HTML allows leaving out are left out.
+//
+// The content of whitespace sensitive elements such as pre, textarea, script and style is written as is.
+func (node *HtmlNode) WriteMinifiedTo(w io.Writer) (int64, error) {
+	writer := &htmlWriter{w: w, format: _MINIFIED_FORMAT}
+	node.write(writer, 0, nil)
+
+	return writer.written, writer.err
+}
+
+// Ends a top level node. In the indented format, each one gets a line of its own.
+func (writer *htmlWriter) endRoot() {
+	if writer.format == _INDENTED_FORMAT {
+		writer.writeString("\n")
+	}
+}
+
+// Transpiles yaml nodes and writes them as HTML to w. It's the same as rendering a template of the nodes
+// without data, so nothing is written to w if a node fails to transpile.
+//
+// Output is buffered, so w doesn't need to be. Options such as WithIndent change how the HTML is laid out,
+// and options such as WithDoctype add what a complete document needs.
+func Render(w io.Writer, yamlNodes []YamlNode, options ...RenderOption) error {
+	template, err := NewTemplate(yamlNodes)
+	if err != nil {
+		return fmt.Errorf("Render failed: %w", err)
+	}
+
+	return template.Render(w, nil, options...)
+}
@@ -0,0 +1,159 @@
+package yaml_tmpl
+
+import (
+	"strings"
+)
+
+// Changes how Render writes HTML.
+type RenderOption func(*renderOptions)
+
+type renderOptions struct {
+	format outputFormat
+	indent string
+	// Document-level options, only applied by Render.
+	doctype bool
+	lang    string
+	charset string
+}
+
+// Collects options into their settings. Later options win over earlier ones.
+func getRenderOptions(options []RenderOption) renderOptions {
+	var settings renderOptions
+	for _, option := range options {
+		option(&settings)
+	}
+
+	return settings
+}
+
+// Writes indented HTML, with block-level elements on lines of their own and indent repeated once per
+// level of nesting. See HtmlNode.WriteIndentedTo.
+func WithIndent(indent string) RenderOption {
+	return func(settings *renderOptions) {
+		settings.format = _INDENTED_FORMAT
+		settings.indent = indent
+	}
+}
+
+// Writes minified HTML. See HtmlNode.WriteMinifiedTo.
+func WithMinify() RenderOption {
+	return func(settings *renderOptions) {
+		settings.format = _MINIFIED_FORMAT
+	}
+}
+
+// Writes <!DOCTYPE html> before the document, so that browsers don't render it in quirks mode.
+func WithDoctype() RenderOption {
+	return func(settings *renderOptions) {
+		settings.doctype = true
+	}
+}
+
+// Sets the lang attribute of the html element, unless it already has one.
+func WithLang(lang string) RenderOption {
+	return func(settings *renderOptions) {
+		settings.lang = lang
+	}
+}
+
+// Adds <meta charset> as the first element of the head, unless it already has one.
+//
+// If there is no head, one is added to the html element. If there is no html element either,
+// the meta element is written before the document.
+func WithCharset(charset string) RenderOption {
+	return func(settings *renderOptions) {
+		settings.charset = charset
+	}
+}
+
+// Determines whether a tag node has an attribute with the given name.
+func (node *HtmlNode) hasAttribute(name string) bool {
+	for _, child := range node.Children {
+		if child.Type == ATTRIBUTE_HTML_NODE && strings.EqualFold(child.Attribute, name) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// Returns the first child tag with the given name, or nil if there is none.
+func (node *HtmlNode) findChildTag(tag string) *HtmlNode {
+	for _, child := range node.Children {
+		if child.Type == TAG_HTML_NODE && strings.EqualFold(child.Tag, tag) {
+			return child
+		}
+	}
+
+	return nil
+}
+
+// Creates a meta element declaring the charset of a document.
+func newCharsetMeta(charset string, parent *HtmlNode) *HtmlNode {
+	meta := &HtmlNode{
+		Type:   TAG_HTML_NODE,
+		Tag:    "meta",
+		Parent: parent,
+	}
+	meta.Children = []*HtmlNode{
+		{Type: ATTRIBUTE_HTML_NODE, Attribute: "charset", Content: charset, Parent: meta},
+	}
+
+	return meta
+}
+
+// Adds a meta charset element as the first child of a head, unless it has one already.
+func (settings renderOptions) addCharset(head *HtmlNode) {
+	for _, child := range head.Children {
+		if child.Type == TAG_HTML_NODE && strings.EqualFold(child.Tag, "meta") && child.hasAttribute("charset") {
+			return
+		}
+	}
+
+	head.Children = append([]*HtmlNode{newCharsetMeta(settings.charset, head)}, head.Children...)
+}
+
+// Applies the document-level options to a transpiled top level node. If the head is a top level node
+// itself, it isn't added to the html element.
+func (settings renderOptions) applyToRoot(node *HtmlNode, hasRootHead bool) {
+	if node.Type != TAG_HTML_NODE {
+		return
+	}
+
+	switch strings.ToLower(node.Tag) {
+	case "html":
+		if settings.lang != "" && !node.hasAttribute("lang") {
+			lang := &HtmlNode{Type: ATTRIBUTE_HTML_NODE, Attribute: "lang", Content: settings.lang, Parent: node}
+			node.Children = append([]*HtmlNode{lang}, node.Children...)
+		}
+
+		if settings.charset == "" {
+			return
+		}
+
+		head := node.findChildTag("head")
+		if head == nil && hasRootHead {
+			return
+		}
+		if head == nil {
+			head = &HtmlNode{Type: TAG_HTML_NODE, Tag: "head", Parent: node}
+			node.Children = append([]*HtmlNode{head}, node.Children...)
+		}
+		settings.addCharset(head)
+	case "head":
+		if settings.charset != "" {
+			settings.addCharset(node)
+		}
+	}
+}
+
+// Determines whether any of the top level nodes is the given element.
+func hasRootTag(yamlNodes []YamlNode, tag string) bool {
+	for _, yamlNode := range yamlNodes {
+		if strings.EqualFold(yamlNode.Key, tag) {
+			return true
+		}
+	}
+
+	return false
+}
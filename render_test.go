@@ -0,0 +1,396 @@
+package yaml_tmpl_test
+
+import (
+	"bytes"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/frodi-karlsson/yaml_tmpl"
+)
+
+// A writer that fails after writing a number of bytes.
+type failingWriter struct {
+	remaining int
+}
+
+var errWriteFailed = errors.New("write failed")
+
+func (writer *failingWriter) Write(content []byte) (int, error) {
+	if len(content) > writer.remaining {
+		written := writer.remaining
+		writer.remaining = 0
+		return written, errWriteFailed
+	}
+
+	writer.remaining -= len(content)
+	return len(content), nil
+}
+
+func TestWriteTo(t *testing.T) {
+	nodes, err := yaml_tmpl.GetYamlNodesFromLines(DOCUMENT_NODE)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	htmlNode, err := nodes[0].Transpile(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var buffer bytes.Buffer
+	written, err := htmlNode.WriteTo(&buffer)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := "<head><title>Stupid YAML Website</title><link rel=\"stylesheet\" type=\"text/css\" href=\"/static/style.css\"></head>"
+	if buffer.String() != expected {
+		t.Errorf("Expected %s, got %s", expected, buffer.String())
+	}
+
+	if written != int64(len(expected)) {
+		t.Errorf("Expected %d bytes written, got %d", len(expected), written)
+	}
+
+	if htmlNode.String() != expected {
+		t.Errorf("Expected String to match WriteTo, got %s", htmlNode.String())
+	}
+}
+
+func TestWriteToError(t *testing.T) {
+	nodes, err := yaml_tmpl.GetYamlNodesFromLines(DOCUMENT_NODE)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	htmlNode, err := nodes[0].Transpile(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	written, err := htmlNode.WriteTo(&failingWriter{remaining: 10})
+	if !errors.Is(err, errWriteFailed) {
+		t.Errorf("Expected the write error, got %v", err)
+	}
+
+	if written != 10 {
+		t.Errorf("Expected 10 bytes written, got %d", written)
+	}
+}
+
+func TestRender(t *testing.T) {
+	nodes, err := yaml_tmpl.GetYamlNodesFromLines(DOCUMENT_NODE)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var out strings.Builder
+	err = yaml_tmpl.Render(&out, nodes)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !strings.HasPrefix(out.String(), "<head>") || !strings.HasSuffix(out.String(), "</body>") {
+		t.Errorf("Unexpected output: %s", out.String())
+	}
+
+	err = yaml_tmpl.Render(&failingWriter{remaining: 10}, nodes)
+	if !errors.Is(err, errWriteFailed) {
+		t.Errorf("Expected the write error, got %v", err)
+	}
+}
+
+func TestRenderWritesNothingOnError(t *testing.T) {
+	// The first node is larger than the output buffer, so it would be written before the second one fails.
+	nodes, err := yaml_tmpl.GetYamlNodesFromLines([]string{
+		"p: \"" + strings.Repeat("a", 8192) + "\"",
+		"br: \"content\"",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var out strings.Builder
+	err = yaml_tmpl.Render(&out, nodes)
+	if err == nil || !strings.Contains(err.Error(), "void element br can't have content") {
+		t.Errorf("Expected an error about the void element, got %v", err)
+	}
+
+	if out.Len() != 0 {
+		t.Errorf("Expected nothing to be written, got %d bytes", out.Len())
+	}
+}
+
+var INDENTED_DOCUMENT = []string{
+	"html:",
+	"  children:",
+	"    - head:",
+	"        children:",
+	"          - title: \"Title\"",
+	"          - meta:",
+	"              charset: \"utf-8\"",
+	"    - body:",
+	"        children:",
+	"          - div:",
+	"              class: \"content\"",
+	"              children:",
+	"                - p:",
+	"                    children:",
+	"                      - raw: \"Some \"",
+	"                      - b: \"bold\"",
+	"                - pre:",
+	"                    children:",
+	"                      - div: \"  kept\"",
+	"          - p:",
+	"              children:",
+	"                - span:",
+	"                    children:",
+	"                      - div: \"inline\"",
+}
+
+func TestWriteIndentedTo(t *testing.T) {
+	nodes, err := yaml_tmpl.GetYamlNodesFromLines(INDENTED_DOCUMENT)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	htmlNode, err := nodes[0].Transpile(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var buffer bytes.Buffer
+	written, err := htmlNode.WriteIndentedTo(&buffer, "  ")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := strings.Join([]string{
+		"<html>",
+		"  <head>",
+		"    <title>Title</title>",
+		"    <meta charset=\"utf-8\">",
+		"  </head>",
+		"  <body>",
+		"    <div class=\"content\">",
+		"      <p>Some <b>bold</b></p>",
+		"      <pre><div>  kept</div></pre>",
+		"    </div>",
+		"    <p><span><div>inline</div></span></p>",
+		"  </body>",
+		"</html>",
+	}, "\n")
+	if buffer.String() != expected {
+		t.Errorf("Expected:\n%s\ngot:\n%s", expected, buffer.String())
+	}
+
+	if written != int64(len(expected)) {
+		t.Errorf("Expected %d bytes written, got %d", len(expected), written)
+	}
+}
+
+func TestRenderWithIndent(t *testing.T) {
+	nodes, err := yaml_tmpl.GetYamlNodesFromLines([]string{
+		"div:",
+		"  children:",
+		"    - p: \"a\"",
+		"p: \"b\"",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var out strings.Builder
+	err = yaml_tmpl.Render(&out, nodes, yaml_tmpl.WithIndent("\t"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := "<div>\n\t<p>a</p>\n</div>\n<p>b</p>\n"
+	if out.String() != expected {
+		t.Errorf("Expected %q, got %q", expected, out.String())
+	}
+}
+
+var MINIFIED_DOCUMENT = []string{
+	"html:",
+	"  lang: en",
+	"  children:",
+	"    - head:",
+	"        children:",
+	"          - title: \"A  title\"",
+	"    - body:",
+	"        children:",
+	"          - p:",
+	"              class: \"intro text\"",
+	"              children:",
+	"                - raw: |",
+	"                    Some   <!-- note -->",
+	"                    <b title=\"a  b\">bold</b>",
+	"                    <pre>  kept  </pre>",
+	"          - ul:",
+	"              children:",
+	"                - li: \"a\"",
+	"                - li: \"b\"",
+	"          - pre: \"  kept\\n  too\"",
+	"          - input:",
+	"              value: \"\"",
+	"              type: text",
+	"          - a:",
+	"              href: /about",
+	"              children:",
+	"                - p: \"inside a link\"",
+}
+
+func TestWriteMinifiedTo(t *testing.T) {
+	nodes, err := yaml_tmpl.GetYamlNodesFromLines(MINIFIED_DOCUMENT)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	htmlNode, err := nodes[0].Transpile(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var buffer bytes.Buffer
+	written, err := htmlNode.WriteMinifiedTo(&buffer)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := "<html lang=en><head><title>A title</title><body>" +
+		"<p class=\"intro text\">Some <b title=\"a  b\">bold</b> <pre>  kept  </pre> " +
+		"<ul><li>a<li>b</ul><pre>  kept\n  too</pre><input value type=text>" +
+		"<a href=/about><p>inside a link</p></a>"
+	if buffer.String() != expected {
+		t.Errorf("Expected:\n%s\ngot:\n%s", expected, buffer.String())
+	}
+
+	if written != int64(len(expected)) {
+		t.Errorf("Expected %d bytes written, got %d", len(expected), written)
+	}
+}
+
+func TestRenderWithMinify(t *testing.T) {
+	nodes, err := yaml_tmpl.GetYamlNodesFromLines([]string{
+		"table:",
+		"  children:",
+		"    - tbody:",
+		"        children:",
+		"          - tr:",
+		"              children:",
+		"                - td: \"a\"",
+		"                - th: \"b\"",
+		"          - tr:",
+		"              children:",
+		"                - td: \"c\"",
+		"dl:",
+		"  children:",
+		"    - dt: \"term\"",
+		"    - dd: \"definition\"",
+		"    - dt: \"last\"",
+		"    - raw: \"<!-- unclosed\"",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var out strings.Builder
+	err = yaml_tmpl.Render(&out, nodes, yaml_tmpl.WithMinify())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := "<table><tbody><tr><td>a<th>b<tr><td>c</table><dl><dt>term<dd>definition<dt>last</dt></dl>"
+	if out.String() != expected {
+		t.Errorf("Expected %q, got %q", expected, out.String())
+	}
+}
+
+func TestRenderWithDocumentOptions(t *testing.T) {
+	documentOptions := []yaml_tmpl.RenderOption{
+		yaml_tmpl.WithDoctype(),
+		yaml_tmpl.WithLang("en"),
+		yaml_tmpl.WithCharset("utf-8"),
+	}
+
+	tests := []struct {
+		lines    []string
+		expected string
+	}{
+		{
+			[]string{
+				"html:",
+				"  children:",
+				"    - head:",
+				"        children:",
+				"          - title: \"Title\"",
+				"    - body: \"Hello\"",
+			},
+			"<!DOCTYPE html><html lang=\"en\"><head><meta charset=\"utf-8\"><title>Title</title></head><body>Hello</body></html>",
+		},
+		{
+			[]string{
+				"html:",
+				"  lang: \"is\"",
+				"  children:",
+				"    - body: \"Hello\"",
+			},
+			"<!DOCTYPE html><html lang=\"is\"><head><meta charset=\"utf-8\"></head><body>Hello</body></html>",
+		},
+		{
+			[]string{
+				"head:",
+				"  children:",
+				"    - meta:",
+				"        charset: \"latin1\"",
+				"html:",
+				"  children:",
+				"    - body: \"Hello\"",
+			},
+			"<!DOCTYPE html><head><meta charset=\"latin1\"></head><html lang=\"en\"><body>Hello</body></html>",
+		},
+		{
+			[]string{
+				"p: \"Hello\"",
+			},
+			"<!DOCTYPE html><meta charset=\"utf-8\"><p>Hello</p>",
+		},
+	}
+
+	for _, test := range tests {
+		nodes, err := yaml_tmpl.GetYamlNodesFromLines(test.lines)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		var out strings.Builder
+		err = yaml_tmpl.Render(&out, nodes, documentOptions...)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if out.String() != test.expected {
+			t.Errorf("Expected %s, got %s", test.expected, out.String())
+		}
+	}
+}
+
+func BenchmarkRenderDocument(b *testing.B) {
+	lines := make([]string, 0, len(DOCUMENT_NODE)*100)
+	for i := 0; i < 100; i++ {
+		lines = append(lines, DOCUMENT_NODE...)
+	}
+
+	nodes, err := yaml_tmpl.GetYamlNodesFromLines(lines)
+	if err != nil {
+		b.Fatal(err)
+	}
+
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		yaml_tmpl.Render(&bytes.Buffer{}, nodes)
+	}
+}
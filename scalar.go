@@ -0,0 +1,250 @@
+package yaml_tmpl
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+// Characters that can't start a plain scalar, as they're reserved or unsupported by the parser.
+const _RESERVED_INDICATORS = "@`!%"
+
+func isQuote(char byte) bool {
+	for _, quoteType := range _QUOTE_TYPES {
+		if rune(char) == quoteType {
+			return true
+		}
+	}
+	return false
+}
+
+func isWhitespace(char byte) bool {
+	return char == ' ' || char == '\t'
+}
+
+// Returns the index the key of a line starts at, skipping indentation and a sequence dash.
+func keyStart(line string) int {
+	return keyColumn(line) - 1
+}
+
+// Returns the index of the colon separating a key from its value, or -1 if there is none.
+//
+// As in YAML, a colon only separates the key from the value if it's followed by whitespace
+// or the end of the line, unless the key is quoted.
+func findMappingColon(line string) int {
+	index := keyStart(line)
+
+	if index < len(line) && isQuote(line[index]) {
+		end := findClosingQuote(line, index)
+		if end == -1 {
+			return -1
+		}
+
+		index = end + 1
+		for index < len(line) && isWhitespace(line[index]) {
+			index++
+		}
+
+		if index < len(line) && line[index] == ':' {
+			return index
+		}
+		return -1
+	}
+
+	for ; index < len(line); index++ {
+		if line[index] == '#' && index > 0 && isWhitespace(line[index-1]) {
+			return -1
+		}
+
+		if line[index] == ':' && (index == len(line)-1 || isWhitespace(line[index+1])) {
+			return index
+		}
+	}
+
+	return -1
+}
+
+// Returns the index of the quote closing the quoted scalar starting at start, or -1 if it's not closed.
+func findClosingQuote(line string, start int) int {
+	quote := line[start]
+
+	for index := start + 1; index < len(line); index++ {
+		char := line[index]
+
+		if quote == '"' && char == '\\' {
+			index++
+			continue
+		}
+
+		if char == quote {
+			// Two single quotes are an escaped single quote.
+			if quote == '\'' && index+1 < len(line) && line[index+1] == '\'' {
+				index++
+				continue
+			}
+			return index
+		}
+	}
+
+	return -1
+}
+
+// Removes the quotes from a quoted key. Plain keys are returned as is.
+func unquoteKey(key string) string {
+	if len(key) < 2 || !isQuote(key[0]) || key[len(key)-1] != key[0] {
+		return key
+	}
+
+	if key[0] == '\'' {
+		return strings.ReplaceAll(key[1:len(key)-1], "''", "'")
+	}
+
+	value, err := unescapeDoubleQuoted(key[1 : len(key)-1])
+	if err != nil {
+		return key[1 : len(key)-1]
+	}
+	return value
+}
+
+// Resolves the escape sequences of a double quoted scalar.
+//
+// Unknown escape sequences are kept as the escaped character.
+func unescapeDoubleQuoted(content string) (string, error) {
+	if !strings.ContainsRune(content, '\\') {
+		return content, nil
+	}
+
+	var builder strings.Builder
+	builder.Grow(len(content))
+
+	for index := 0; index < len(content); index++ {
+		char := content[index]
+		if char != '\\' || index == len(content)-1 {
+			builder.WriteByte(char)
+			continue
+		}
+
+		index++
+		escaped := content[index]
+
+		switch escaped {
+		case '0':
+			builder.WriteByte(0)
+		case 'a':
+			builder.WriteByte('\a')
+		case 'b':
+			builder.WriteByte('\b')
+		case 't':
+			builder.WriteByte('\t')
+		case 'n':
+			builder.WriteByte('\n')
+		case 'v':
+			builder.WriteByte('\v')
+		case 'f':
+			builder.WriteByte('\f')
+		case 'r':
+			builder.WriteByte('\r')
+		case 'e':
+			builder.WriteByte(0x1b)
+		case 'N':
+			builder.WriteRune('\u0085')
+		case '_':
+			builder.WriteRune('\u00a0')
+		case 'L':
+			builder.WriteRune('\u2028')
+		case 'P':
+			builder.WriteRune('\u2029')
+		case 'x', 'u', 'U':
+			length := map[byte]int{'x': 2, 'u': 4, 'U': 8}[escaped]
+			if index+length >= len(content) {
+				return "", fmt.Errorf("UnescapeDoubleQuoted failed: incomplete \\%c escape", escaped)
+			}
+
+			code, err := strconv.ParseUint(content[index+1:index+1+length], 16, 32)
+			if err != nil || !utf8.ValidRune(rune(code)) {
+				return "", fmt.Errorf("UnescapeDoubleQuoted failed: invalid \\%c escape", escaped)
+			}
+
+			builder.WriteRune(rune(code))
+			index += length
+		default:
+			builder.WriteByte(escaped)
+		}
+	}
+
+	return builder.String(), nil
+}
+
+// Parses a quoted scalar starting at valueIndex in the line. Only a comment may follow it.
+func parseQuotedScalar(state *parseState, line sourceLine, valueIndex int) (string, error) {
+	end := findClosingQuote(line.text, valueIndex)
+	if end == -1 {
+		return "", fmt.Errorf("ParseQuotedScalar failed: %w", state.errorAt(line, valueIndex+1, "missing closing quote"))
+	}
+
+	rest := strings.TrimLeft(line.text[end+1:], " \t")
+	if rest != "" && rest[0] != '#' {
+		column := len(line.text) - len(rest) + 1
+		return "", fmt.Errorf("ParseQuotedScalar failed: %w", state.errorAt(line, column, "unexpected content after quoted value"))
+	}
+
+	content := line.text[valueIndex+1 : end]
+
+	if line.text[valueIndex] == '\'' {
+		return strings.ReplaceAll(content, "''", "'"), nil
+	}
+
+	value, err := unescapeDoubleQuoted(content)
+	if err != nil {
+		return "", fmt.Errorf("ParseQuotedScalar failed: %w", state.errorAt(line, valueIndex+1, "%s", err.Error()))
+	}
+
+	return value, nil
+}
+
+// Parses a plain (unquoted) scalar starting at valueIndex in the line.
+//
+// The value ends at a comment, which is a # preceded by whitespace. Surrounding whitespace is removed.
+func parsePlainScalar(state *parseState, line sourceLine, valueIndex int) (string, error) {
+	value := line.text[valueIndex:]
+
+	if strings.IndexByte(_RESERVED_INDICATORS, value[0]) != -1 {
+		return "", fmt.Errorf("ParsePlainScalar failed: %w", state.errorAt(line, valueIndex+1, "a plain value can't start with %q, quote the value instead", value[0]))
+	}
+
+	for index := 0; index < len(value); index++ {
+		char := value[index]
+
+		if char == '#' && index > 0 && isWhitespace(value[index-1]) {
+			value = value[:index]
+			break
+		}
+
+		if char == ':' && (index == len(value)-1 || isWhitespace(value[index+1])) {
+			return "", fmt.Errorf("ParsePlainScalar failed: %w", state.errorAt(line, valueIndex+index+1, "a plain value can't contain \": \", quote the value instead"))
+		}
+	}
+
+	return strings.TrimRight(value, " \t\r"), nil
+}
+
+// Determines whether a value is a plain scalar, or missing. Quoted and block scalars are always strings.
+func isPlainValue(value string) bool {
+	return value == "" || !isQuote(value[0]) && !isBlockScalarHeader(value)
+}
+
+// Determines whether a plain scalar is true in the YAML core schema.
+func isTrueScalar(content string) bool {
+	return content == "true" || content == "True" || content == "TRUE"
+}
+
+// Determines whether a plain scalar is false in the YAML core schema.
+func isFalseScalar(content string) bool {
+	return content == "false" || content == "False" || content == "FALSE"
+}
+
+// Determines whether a plain scalar is null in the YAML core schema. A missing value is null too.
+func isNullScalar(content string) bool {
+	return content == "" || content == "~" || content == "null" || content == "Null" || content == "NULL"
+}
@@ -0,0 +1,328 @@
+package yaml_tmpl
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"maps"
+	"strings"
+)
+
+// A parsed template, which can be rendered with data.
+//
+// Expressions like ${ .user.name } in text, attribute values and keys are replaced with values from the data.
+// The values are escaped for the context they end up in, just like the rest of the template.
+type Template struct {
+	nodes []YamlNode
+	// Functions that can be called from expressions, by name.
+	functions FuncMap
+	// The compiled expressions of the template, by the key or content they are in.
+	compiled map[string]*interpolation
+	// Components declared with component:, by name.
+	components map[string]*component
+}
+
+// Creates a template from parsed yaml nodes, which can call the standard functions.
+// Use an Engine to add functions of your own.
+//
+// Expressions are compiled up front, so that a mistake in one is reported even if it's never evaluated.
+// Such errors can be retrieved as a *ParseError using errors.As.
+func NewTemplate(nodes []YamlNode) (*Template, error) {
+	return NewEngine().NewTemplate(nodes)
+}
+
+// Creates a ParseError for an expression in the key or content of a node that failed to compile. It points
+// at where the expression went wrong in the source lines of the node, if the expression can be found in them.
+func (node *YamlNode) compileError(content string, err error) *ParseError {
+	parseError := &ParseError{Position: node.Position, Message: err.Error()}
+
+	var expressionErr *expressionError
+	if !errors.As(err, &expressionErr) {
+		return parseError
+	}
+	parseError.Message = expressionErr.message
+
+	if len(node.source) > 0 {
+		parseError.Excerpt = createExcerpt(node.source[0], node.Position.Column)
+	}
+
+	// Look for the expression from its ${ up to where it went wrong, which is on a single line.
+	start := strings.LastIndex(content[:min(expressionErr.offset+len(_EXPRESSION_START), len(content))], _EXPRESSION_START)
+	if start == -1 {
+		return parseError
+	}
+
+	end := max(expressionErr.offset, start+len(_EXPRESSION_START))
+	if strings.Contains(content[start:end], "\n") {
+		return parseError
+	}
+
+	for _, line := range node.source {
+		index := strings.Index(line.text, content[start:end])
+		if index == -1 {
+			continue
+		}
+
+		column := index + 1 + expressionErr.offset - start
+		parseError.Position = Position{
+			File:    node.Position.File,
+			Line:    line.number,
+			Column:  column,
+			EndLine: line.number,
+		}
+		parseError.Excerpt = createExcerpt(line, column)
+		break
+	}
+
+	return parseError
+}
+
+// Compiles the expressions in the key and content of a node and its children.
+func (template *Template) compile(node *YamlNode) error {
+	sources := make([]string, 0, 2)
+	if hasExpression(node.Key) {
+		sources = append(sources, node.Key)
+	}
+	if node.Type == RAW_YAML_NODE && hasExpression(node.Content) {
+		sources = append(sources, node.Content)
+	}
+
+	for _, source := range sources {
+		if _, exists := template.compiled[source]; exists {
+			continue
+		}
+
+		compiled, err := compileInterpolation(source, template.functions)
+		if err != nil {
+			return node.compileError(source, err)
+		}
+		template.compiled[source] = compiled
+	}
+
+	for _, child := range node.Children {
+		err := template.compile(child)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// Parses the named yaml template in a file system. Use an Engine to add functions of your own.
+func ParseTemplateFS(fsys fs.FS, name string) (*Template, error) {
+	return NewEngine().ParseTemplateFS(fsys, name)
+}
+
+// State used while transpiling a template.
+type transpileContext struct {
+	// The value expressions are evaluated against, written as a dot.
+	data any
+	// The data the template is rendered with, written as $.
+	root any
+	// Variables bound by loops, written as $name.
+	variables map[string]any
+	// Functions that can be called from expressions, by name.
+	functions FuncMap
+	// Expressions compiled ahead of time, by the key or content they are in.
+	compiled map[string]*interpolation
+	// Components that can be used as tags, by name.
+	components map[string]*component
+	// The use of the component being transpiled, or nil outside of components.
+	call *componentCall
+}
+
+// Creates a context for the body of a loop, with the dot set to data and variables added.
+func (context *transpileContext) with(data any, variables map[string]any) *transpileContext {
+	merged := make(map[string]any, len(context.variables)+len(variables))
+	maps.Copy(merged, context.variables)
+	maps.Copy(merged, variables)
+
+	inner := *context
+	inner.data = data
+	inner.variables = merged
+
+	return &inner
+}
+
+// Returns the compiled expressions of a key or content. Those that weren't compiled ahead of time,
+// such as when transpiling without a template, are compiled now.
+func (context *transpileContext) compile(content string) (*interpolation, error) {
+	if compiled, exists := context.compiled[content]; exists {
+		return compiled, nil
+	}
+
+	return compileInterpolation(content, context.functions)
+}
+
+// Evaluates content that is a single expression, like ${ .items }. Returns false if it's anything else.
+func (context *transpileContext) evaluateSingle(content string) (any, bool, error) {
+	compiled, err := context.compile(content)
+	if err != nil {
+		return nil, false, err
+	}
+
+	if compiled.single == nil {
+		return nil, false, nil
+	}
+
+	value, err := compiled.single.evaluate(context)
+	return value, true, err
+}
+
+// Determines whether a resolved key is a valid tag or attribute name.
+func isValidName(name string) bool {
+	if name == "" || !isLetter(name[0]) {
+		return false
+	}
+
+	for index := 1; index < len(name); index++ {
+		char := name[index]
+		if !isLetter(char) && !('0' <= char && char <= '9') && strings.IndexByte("-_:.", char) == -1 {
+			return false
+		}
+	}
+
+	return true
+}
+
+// Evaluates the expressions in a scalar, formatting their values for the language of the scalar.
+//
+// A scalar that is a single expression keeps the type of its value, so booleans and nil are plain
+// true, false and null, and anything else is a string. Otherwise the result is a string.
+func (context *transpileContext) resolveScalar(content string, language valueLanguage) (string, bool, error) {
+	compiled, err := context.compile(content)
+	if err != nil {
+		return "", false, err
+	}
+
+	if compiled.single != nil {
+		value, err := compiled.single.evaluate(context)
+		if err != nil {
+			return "", false, err
+		}
+
+		switch value := value.(type) {
+		case nil:
+			return "", true, nil
+		case bool:
+			return formatValue(value), true, nil
+		}
+
+		formatted, err := language.format(value, 0)
+		return formatted, false, err
+	}
+
+	resolved, err := compiled.render(context, language)
+	return resolved, false, err
+}
+
+// Returns the node with the expressions in its key and content evaluated. Nodes without
+// expressions are returned as they are, and others are copied.
+//
+// The language of the content is looked up by its resolved key, and is HTML if languageOf is nil.
+func (context *transpileContext) resolve(node *YamlNode, languageOf func(key string) valueLanguage) (*YamlNode, error) {
+	keyHasExpression := hasExpression(node.Key)
+	contentHasExpression := node.Type == RAW_YAML_NODE && hasExpression(node.Content)
+	if !keyHasExpression && !contentHasExpression {
+		return node, nil
+	}
+
+	resolved := *node
+
+	if keyHasExpression {
+		compiled, err := context.compile(node.Key)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", node.Position, err)
+		}
+
+		key, err := compiled.render(context, _HTML_LANGUAGE)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", node.Position, err)
+		}
+
+		if !isValidName(key) {
+			return nil, fmt.Errorf("%s: %q is not a valid tag or attribute name", node.Position, key)
+		}
+
+		resolved.Key = key
+	}
+
+	if contentHasExpression {
+		language := _HTML_LANGUAGE
+		if languageOf != nil {
+			language = languageOf(resolved.Key)
+		}
+
+		content, plain, err := context.resolveScalar(node.Content, language)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", node.Position, err)
+		}
+
+		resolved.Content = content
+		resolved.Plain = plain
+	}
+
+	return &resolved, nil
+}
+
+// Transpiles the template with data and writes it as HTML to w.
+//
+// All top level nodes are transpiled before anything is written, so nothing is written to w if one of them
+// fails. Output is buffered, so w doesn't need to be. Options such as WithIndent change how the HTML is
+// laid out, and options such as WithDoctype add what a complete document needs.
+func (template *Template) Render(w io.Writer, data any, options ...RenderOption) error {
+	settings := getRenderOptions(options)
+	context := &transpileContext{
+		data:       data,
+		root:       data,
+		functions:  template.functions,
+		compiled:   template.compiled,
+		components: template.components,
+	}
+
+	var chain conditionChain
+	roots := make([]*HtmlNode, 0, len(template.nodes))
+
+	for index := range template.nodes {
+		htmlNodes, err := chain.transpile(context, &template.nodes[index], nil, true)
+		if err != nil {
+			return fmt.Errorf("Render failed to transpile: %w", err)
+		}
+
+		roots = append(roots, htmlNodes...)
+	}
+
+	buffered := bufio.NewWriter(w)
+	writer := &htmlWriter{w: buffered, format: settings.format, indent: settings.indent}
+
+	if settings.doctype {
+		writer.writeString("<!DOCTYPE html>")
+		writer.endRoot()
+	}
+
+	hasRootHead := hasRootTag(template.nodes, "head")
+	if settings.charset != "" && !hasRootHead && !hasRootTag(template.nodes, "html") {
+		newCharsetMeta(settings.charset, nil).write(writer, 0, nil)
+		writer.endRoot()
+	}
+
+	for _, htmlNode := range roots {
+		settings.applyToRoot(htmlNode, hasRootHead)
+		htmlNode.write(writer, 0, nil)
+		writer.endRoot()
+
+		if writer.err != nil {
+			return fmt.Errorf("Render failed to write: %w", writer.err)
+		}
+	}
+
+	err := buffered.Flush()
+	if err != nil {
+		return fmt.Errorf("Render failed to write: %w", err)
+	}
+
+	return nil
+}
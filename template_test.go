@@ -0,0 +1,460 @@
+package yaml_tmpl_test
+
+import (
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/frodi-karlsson/yaml_tmpl"
+)
+
+type templateUser struct {
+	Name    string
+	Admin   bool
+	Profile *templateProfile
+}
+
+type templateProfile struct {
+	URL string
+}
+
+func (user templateUser) Greeting() string {
+	return "Hello, " + user.Name
+}
+
+var DATA_BOUND_TEMPLATE = []string{
+	"${ .tag }:",
+	"  class: \"user ${ .user.Name }\"",
+	"  hidden: ${ .user.Admin }",
+	"  data: {name: ${ .user.Name }}",
+	"  children:",
+	"    - h1: ${ .user.Greeting }",
+	"    - a:",
+	"        href: ${ .user.Profile.URL }",
+	"        innerText: \"${ .count } posts\"",
+	"    - p: ${ .missing }",
+	"    - p: \"Costs $${ .price }\"",
+	"    - raw: ${ .trusted }",
+}
+
+func renderLines(t *testing.T, lines []string, data any) (string, error) {
+	t.Helper()
+
+	nodes, err := yaml_tmpl.GetYamlNodesFromLines(lines)
+	if err != nil {
+		return "", err
+	}
+
+	template, err := yaml_tmpl.NewTemplate(nodes)
+	if err != nil {
+		return "", err
+	}
+
+	var out strings.Builder
+	err = template.Render(&out, data)
+	return out.String(), err
+}
+
+func TestTemplateRender(t *testing.T) {
+	html, err := renderLines(t, DATA_BOUND_TEMPLATE, map[string]any{
+		"tag": "section",
+		"user": templateUser{
+			Name:    "<Ada>",
+			Admin:   false,
+			Profile: &templateProfile{URL: "javascript:alert(1)"},
+		},
+		"count":   3,
+		"trusted": "<b>bold</b>",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := "<section class=\"user &lt;Ada&gt;\" data-name=\"&lt;Ada&gt;\">" +
+		"<h1>Hello, &lt;Ada&gt;</h1>" +
+		"<a href=\"about:invalid#unsafe-url\">3 posts</a>" +
+		"<p></p>" +
+		"<p>Costs ${ .price }</p>" +
+		"<b>bold</b>" +
+		"</section>"
+	if html != expected {
+		t.Errorf("Expected %s, got %s", expected, html)
+	}
+}
+
+func TestTemplateRenderBooleans(t *testing.T) {
+	html, err := renderLines(t, []string{
+		"input:",
+		"  disabled: ${ .disabled }",
+		"  required: ${ .required }",
+		"  value: ${ .value }",
+		"  class: {active: ${ .disabled }}",
+	}, map[string]any{
+		"disabled": true,
+		"required": nil,
+		"value":    "false",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := "<input disabled value=\"false\" class=\"active\">"
+	if html != expected {
+		t.Errorf("Expected %s, got %s", expected, html)
+	}
+}
+
+func TestTemplateRenderErrors(t *testing.T) {
+	tests := []struct {
+		lines    []string
+		message  string
+		position string
+	}{
+		{[]string{"p: ${ .user.Email }"}, "has no field Email", "1:1"},
+		{[]string{"p: ${ .user.Profile.URL }"}, "can't get URL of nil", "1:1"},
+		{[]string{"p: \"${ .user.Name\""}, "missing a closing }", "1:5"},
+		{[]string{"${ .tag }: a"}, "not a valid tag or attribute name", "1:1"},
+		{[]string{"p: ${ user }"}, "function \"user\" is not defined", "1:7"},
+	}
+
+	data := map[string]any{
+		"user": templateUser{Name: "Ada"},
+		"tag":  "p onclick=alert(1)",
+	}
+
+	for _, test := range tests {
+		_, err := renderLines(t, test.lines, data)
+		if err == nil || !strings.Contains(err.Error(), test.message) {
+			t.Errorf("Expected an error containing %q for %v, got %v", test.message, test.lines, err)
+		}
+
+		if err != nil && !strings.Contains(err.Error(), test.position) {
+			t.Errorf("Expected the error to have position %s, got %v", test.position, err)
+		}
+	}
+}
+
+func TestTemplateRenderScriptValues(t *testing.T) {
+	data := map[string]any{
+		"name":  "<b>\"x'",
+		"count": 3,
+		"color": "red",
+	}
+
+	html, err := renderLines(t, []string{
+		"script: \"var x = '${ .name }'; var y = ${ .name }; var n = ${ .count };\"",
+		"style: \"a::after { content: '${ .name }'; color: ${ .color } }\"",
+		"button:",
+		"  onclick: \"f('${ .name }')\"",
+		"  style: \"color: ${ .color }\"",
+		"  innerText: ${ .name }",
+	}, data)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := "<script>var x = '\\u003cb\\u003e\\u0022x\\u0027'; var y =  \"\\u003cb\\u003e\\\"x'\" ; var n =  3 ;</script>" +
+		"<style>a::after { content: '\\3c b\\3e \\22 x\\27 '; color: red }</style>" +
+		"<button onclick=\"f(&#39;\\u003cb\\u003e\\u0022x\\u0027&#39;)\" style=\"color: red\">&lt;b&gt;\"x'</button>"
+	if html != expected {
+		t.Errorf("Expected %s, got %s", expected, html)
+	}
+
+	data["color"] = "red; background: url(x)"
+	_, err = renderLines(t, []string{"p:", "  style: \"color: ${ .color }\""}, data)
+	if err == nil || !strings.Contains(err.Error(), "can't be put in CSS outside of a string") {
+		t.Errorf("Expected an error about the CSS value, got %v", err)
+	}
+}
+
+func TestTranspileWithoutData(t *testing.T) {
+	nodes, err := yaml_tmpl.GetYamlNodesFromLines([]string{"p: ${ .name }"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, err = nodes[0].Transpile(nil)
+	if err == nil {
+		t.Error("Expected an error for an expression without data")
+	}
+}
+
+var EACH_TEMPLATE = []string{
+	"ul:",
+	"  children:",
+	"    - each:",
+	"        in: ${ .products }",
+	"        as: product",
+	"        index: i",
+	"        li:",
+	"          data: {index: ${ $i }}",
+	"          innerText: \"${ $product.Name } by ${ $.shop }\"",
+	"dl:",
+	"  each:",
+	"    in: ${ .attributes }",
+	"    as: value",
+	"    index: name",
+	"    data-${ $name }: ${ $value }",
+	"  children:",
+	"    - each:",
+	"        in: ${ .attributes }",
+	"        index: name",
+	"        dt: ${ $name }",
+	"        dd: ${ . }",
+	"    - each:",
+	"        in: ${ .missing }",
+	"        dt: never",
+}
+
+func TestTemplateRenderEach(t *testing.T) {
+	html, err := renderLines(t, EACH_TEMPLATE, map[string]any{
+		"shop": "Shop",
+		"products": []templateUser{
+			{Name: "Lamp"},
+			{Name: "Desk"},
+		},
+		"attributes": map[string]string{
+			"size":  "large",
+			"color": "red",
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := "<ul><li data-index=\"0\">Lamp by Shop</li><li data-index=\"1\">Desk by Shop</li></ul>" +
+		"<dl data-color=\"red\" data-size=\"large\"><dt>color</dt><dd>red</dd><dt>size</dt><dd>large</dd></dl>"
+	if html != expected {
+		t.Errorf("Expected %s, got %s", expected, html)
+	}
+}
+
+func TestTemplateRenderEachErrors(t *testing.T) {
+	tests := []struct {
+		lines   []string
+		message string
+	}{
+		{[]string{"ul:", "  children:", "    - each:", "        li: a"}, "needs an in"},
+		{[]string{"ul:", "  children:", "    - each:", "        in: items", "        li: a"}, "single expression"},
+		{[]string{"ul:", "  children:", "    - each:", "        in: ${ .name }", "        li: a"}, "can't loop over string"},
+		{[]string{"ul:", "  children:", "    - each:", "        in: ${ .list }", "        as: \"my item\"", "        li: a"}, "variable name"},
+		{[]string{"ul:", "  children:", "    - each:", "        in: ${ .list }", "        li: ${ $item }"}, "$item is not defined"},
+	}
+
+	data := map[string]any{
+		"name": "Ada",
+		"list": []int{1},
+	}
+
+	for _, test := range tests {
+		_, err := renderLines(t, test.lines, data)
+		if err == nil || !strings.Contains(err.Error(), test.message) {
+			t.Errorf("Expected an error containing %q for %v, got %v", test.message, test.lines, err)
+		}
+	}
+}
+
+var CONDITION_TEMPLATE = []string{
+	"div:",
+	"  if:",
+	"    test: ${ .user.Admin }",
+	"    class: admin",
+	"  else:",
+	"    class: user",
+	"  children:",
+	"    - if:",
+	"        test: ${ .notifications }",
+	"        p: \"You have mail\"",
+	"    - else-if:",
+	"        test: ${ .user.Name }",
+	"        p: \"Hello ${ .user.Name }\"",
+	"    - else:",
+	"        p: \"Hello stranger\"",
+	"    - each:",
+	"        in: ${ .items }",
+	"        if:",
+	"          test: ${ . }",
+	"          span: ${ . }",
+	"        else:",
+	"          hr: \"\"",
+	"if:",
+	"  test: false",
+	"  p: never",
+}
+
+func TestTemplateRenderConditions(t *testing.T) {
+	tests := []struct {
+		data     map[string]any
+		expected string
+	}{
+		{
+			map[string]any{"user": templateUser{Name: "Ada", Admin: true}, "notifications": []string{"a"}, "items": []int{1, 0, 2}},
+			"<div class=\"admin\"><p>You have mail</p><span>1</span><hr><span>2</span></div>",
+		},
+		{
+			map[string]any{"user": templateUser{Name: "Ada"}, "notifications": []string{}},
+			"<div class=\"user\"><p>Hello Ada</p></div>",
+		},
+		{
+			map[string]any{"user": templateUser{}},
+			"<div class=\"user\"><p>Hello stranger</p></div>",
+		},
+	}
+
+	for _, test := range tests {
+		html, err := renderLines(t, CONDITION_TEMPLATE, test.data)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if html != test.expected {
+			t.Errorf("Expected %s, got %s", test.expected, html)
+		}
+	}
+}
+
+func TestTemplateRenderConditionErrors(t *testing.T) {
+	tests := []struct {
+		lines   []string
+		message string
+	}{
+		{[]string{"else:", "  p: a"}, "has to follow an if"},
+		{[]string{"if:", "  test: true", "  p: a", "p: b", "else-if:", "  test: true", "  p: c"}, "has to follow an if"},
+		{[]string{"if:", "  p: a"}, "needs a test"},
+		{[]string{"if:", "  test: yes", "  p: a"}, "single expression"},
+		{[]string{"if:", "  test: true", "  p: a", "else:", "  test: true", "  p: b"}, "can't have a test"},
+	}
+
+	for _, test := range tests {
+		_, err := renderLines(t, test.lines, nil)
+		if err == nil || !strings.Contains(err.Error(), test.message) {
+			t.Errorf("Expected an error containing %q for %v, got %v", test.message, test.lines, err)
+		}
+	}
+}
+
+func TestTemplateRenderExpressions(t *testing.T) {
+	tests := []struct {
+		expression string
+		expected   string
+	}{
+		{".user.Name + \" has \" + .count + ' posts'", "Ada has 3 posts"},
+		{".count * 2 + 1", "7"},
+		{"(.count + 1) * 2", "8"},
+		{".count / 2", "1"},
+		{".count % 2", "1"},
+		{".price * 2", "2.5"},
+		{"-.count + 1", "-2"},
+		{".count / 0.5", "6"},
+		{".items[1]", "b"},
+		{".items[.count - 1]", "c"},
+		{".prices[\"apple\"]", "2"},
+		{".prices.pear", ""},
+		{"$.user.Name", "Ada"},
+		{"len(.items)", "3"},
+		{".items | len", "3"},
+		{".count == 3 && .user.Name != \"Bob\"", "true"},
+		{".count > 3 || !.user.Admin", "true"},
+		{".count >= 3.0", "true"},
+		{"\"abc\" < \"abd\"", "true"},
+		{".missing == nil", "true"},
+		{"\"a}b\"", "a}b"},
+	}
+
+	data := map[string]any{
+		"user":   templateUser{Name: "Ada"},
+		"count":  3,
+		"price":  1.25,
+		"items":  []string{"a", "b", "c"},
+		"prices": map[string]int{"apple": 2},
+	}
+
+	for _, test := range tests {
+		html, err := renderLines(t, []string{"p: \"${ " + strings.ReplaceAll(test.expression, "\"", "\\\"") + " }\""}, data)
+		if err != nil {
+			t.Fatalf("Unexpected error for %s: %v", test.expression, err)
+		}
+
+		expected := "<p>" + test.expected + "</p>"
+		if html != expected {
+			t.Errorf("Expected %s for %s, got %s", expected, test.expression, html)
+		}
+	}
+}
+
+func TestTemplateRenderExpressionErrors(t *testing.T) {
+	tests := []struct {
+		expression string
+		message    string
+	}{
+		{".count / 0", "division by zero"},
+		{".user.Name - 1", "can't use - on string and int"},
+		{".user < 1", "can't compare"},
+		{".items[\"a\"]", "can't index []string with string"},
+		{".items[5]", "out of range"},
+		{"len(.count)", "can't get the length of int"},
+		{"len(.items, .items)", "takes 1 arguments, got 2"},
+	}
+
+	data := map[string]any{
+		"user":  templateUser{Name: "Ada"},
+		"count": 3,
+		"items": []string{"a"},
+	}
+
+	for _, test := range tests {
+		_, err := renderLines(t, []string{"p: \"${ " + strings.ReplaceAll(test.expression, "\"", "\\\"") + " }\""}, data)
+		if err == nil || !strings.Contains(err.Error(), test.message) {
+			t.Errorf("Expected an error containing %q for %s, got %v", test.message, test.expression, err)
+		}
+	}
+}
+
+func TestNewTemplateCompileErrors(t *testing.T) {
+	tests := []struct {
+		expression string
+		message    string
+		column     int
+	}{
+		{"${ .a + }", "unexpected end of expression", 19},
+		{"${ .a .b }", "unexpected \".b\"", 17},
+		{"text ${ (.a }", "expected \")\"", 23},
+		{"${ shout(.a) }", "function \"shout\" is not defined", 14},
+		{"${ .a | 1 }", "expected a function after |", 19},
+		{"${ 'a }", "missing a closing }", 11},
+		{"${ .a ? .b }", "unexpected '?'", 17},
+		{"${ }", "empty expression", 13},
+	}
+
+	for _, test := range tests {
+		nodes, err := yaml_tmpl.GetYamlNodesFromLines([]string{
+			"div:",
+			"  children:",
+			"    - p: ok",
+			"    - p: \"" + test.expression + "\"",
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		_, err = yaml_tmpl.NewTemplate(nodes)
+
+		var parseError *yaml_tmpl.ParseError
+		if !errors.As(err, &parseError) {
+			t.Errorf("Expected a ParseError for %s, got %v", test.expression, err)
+			continue
+		}
+
+		if !strings.Contains(parseError.Message, test.message) {
+			t.Errorf("Expected an error containing %q for %s, got %v", test.message, test.expression, err)
+		}
+
+		if parseError.Position.Line != 4 || parseError.Position.Column != test.column {
+			t.Errorf("Expected the error to point at 4:%d for %s, got %v", test.column, test.expression, parseError.Position)
+		}
+
+		if !strings.HasPrefix(parseError.Excerpt, " 4 |     - p: \""+test.expression) {
+			t.Errorf("Expected an excerpt of line 4 for %s, got %q", test.expression, parseError.Excerpt)
+		}
+	}
+}
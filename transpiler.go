@@ -1,5 +1,10 @@
 package yaml_tmpl
 
+import (
+	"fmt"
+	"strings"
+)
+
 type HtmlNodeType int
 
 const (
@@ -21,34 +26,66 @@ type HtmlNode struct {
 	Attribute string
 	// Only used if Type == RAW_NODE
 	Content string
+	// Only used if Type == RAW_NODE
+	//
+	// If true, Content is written as is instead of being escaped. This is only set for `raw:` nodes.
+	Raw bool
+	// Only used if Type == ATTRIBUTE_NODE
+	//
+	// If true, the attribute is written without a value, like `<input disabled>`.
+	Boolean bool
 	// Only used if Type == TAG_NODE
 	Children []*HtmlNode
 	// Nil if this is a root node.
 	Parent *HtmlNode
 }
 
+// Elements that can't have any content, and are written without a closing tag.
+var _VOID_ELEMENTS = map[string]bool{
+	"area":   true,
+	"base":   true,
+	"br":     true,
+	"col":    true,
+	"embed":  true,
+	"hr":     true,
+	"img":    true,
+	"input":  true,
+	"link":   true,
+	"meta":   true,
+	"source": true,
+	"track":  true,
+	"wbr":    true,
+}
+
+// Whether a tag is a void element, such as meta or br.
+func isVoidElement(tag string) bool {
+	return _VOID_ELEMENTS[strings.ToLower(tag)]
+}
+
 // Transpiles a raw node to an html node. A raw node is a representation
 // of `tag: "content"` in yaml.
-func (node *YamlNode) transpileRawNode(parent *HtmlNode) *HtmlNode {
-	isRootHtmlElement := parent == nil
-	isChildElement := node.Parent != nil && node.Parent.Key == "children"
-	isAnyHtmlElement := isRootHtmlElement || isChildElement
+//
+// As an element, the node is a tag. Otherwise it's an attribute, or the innerText of its parent.
+func (node *YamlNode) transpileRawNode(parent *HtmlNode, asElement bool) (*HtmlNode, error) {
+	// Sequence entries without a key, like `- "text"`, are text.
+	if node.Key == "" {
+		return &HtmlNode{
+			Type:    RAW_HTML_NODE,
+			Content: node.Content,
+			Parent:  parent,
+		}, nil
+	}
 
-	if !isAnyHtmlElement {
+	if !asElement {
 		// Handle attributes and innerText separately
 		if node.Key == "innerText" {
 			return &HtmlNode{
 				Type:    RAW_HTML_NODE,
 				Content: node.Content,
 				Parent:  parent,
-			}
-		}
-		return &HtmlNode{
-			Type:      ATTRIBUTE_HTML_NODE,
-			Attribute: node.Key,
-			Content:   node.Content,
-			Parent:    parent,
+			}, nil
 		}
+		return node.transpileAttribute(parent), nil
 	}
 
 	rawNode := &HtmlNode{
@@ -58,7 +95,22 @@ func (node *YamlNode) transpileRawNode(parent *HtmlNode) *HtmlNode {
 	}
 
 	if node.Key == "raw" {
-		return rawNode
+		rawNode.Raw = true
+		return rawNode, nil
+	}
+
+	// A void element can't have content, but `br: ""` is the way to write one without attributes.
+	if isVoidElement(node.Key) {
+		if node.Content != "" {
+			return nil, fmt.Errorf("TranspileRawNode failed: void element %s can't have content", node.Key)
+		}
+
+		return &HtmlNode{
+			Type:     TAG_HTML_NODE,
+			Tag:      node.Key,
+			Children: []*HtmlNode{},
+			Parent:   parent,
+		}, nil
 	}
 
 	if len(node.Children) == 0 || node.Key == "innerText" {
@@ -67,10 +119,36 @@ func (node *YamlNode) transpileRawNode(parent *HtmlNode) *HtmlNode {
 			Tag:      node.Key,
 			Children: []*HtmlNode{rawNode},
 			Parent:   parent,
-		}
+		}, nil
 	}
 
 	// Handle unexpected case
+	return &HtmlNode{
+		Type:      ATTRIBUTE_HTML_NODE,
+		Attribute: node.Key,
+		Content:   node.Content,
+		Parent:    parent,
+	}, nil
+}
+
+// Transpiles a raw node to an attribute. Plain true makes a boolean attribute, and plain false or null
+// leave the attribute out, in which case nil is returned.
+func (node *YamlNode) transpileAttribute(parent *HtmlNode) *HtmlNode {
+	if node.Plain {
+		if isFalseScalar(node.Content) || isNullScalar(node.Content) {
+			return nil
+		}
+
+		if isTrueScalar(node.Content) {
+			return &HtmlNode{
+				Type:      ATTRIBUTE_HTML_NODE,
+				Attribute: node.Key,
+				Boolean:   true,
+				Parent:    parent,
+			}
+		}
+	}
+
 	return &HtmlNode{
 		Type:      ATTRIBUTE_HTML_NODE,
 		Attribute: node.Key,
@@ -81,7 +159,11 @@ func (node *YamlNode) transpileRawNode(parent *HtmlNode) *HtmlNode {
 
 // Transpiles a children node to an html node. A children node is a representation
 // of `tag: anything: ...` in yaml.
-func (node *YamlNode) transpileChildrenNode(parent *HtmlNode) *HtmlNode {
+func (node *YamlNode) transpileChildrenNode(context *transpileContext, parent *HtmlNode) (*HtmlNode, error) {
+	if node.Key == "" {
+		return nil, fmt.Errorf("TranspileChildrenNode failed: %s: a list without a key, like - [a, b], needs a tag to be an element", node.Position)
+	}
+
 	htmlNode := HtmlNode{
 		Type:     TAG_HTML_NODE,
 		Tag:      node.Key,
@@ -89,57 +171,120 @@ func (node *YamlNode) transpileChildrenNode(parent *HtmlNode) *HtmlNode {
 		Parent:   parent,
 	}
 
+	isVoid := isVoidElement(node.Key)
+
+	// Conditions can be used among attributes too.
+	var chain conditionChain
+
 	for _, child := range node.Children {
 		// children: is special syntax to denote child elements.
 		if child.Type == CHILDREN_YAML_NODE && child.Key == "children" {
-			for _, grandchild := range child.Children {
-				htmlNode.Children = append(htmlNode.Children, grandchild.Transpile(&htmlNode))
+			if isVoid && len(child.Children) > 0 {
+				return nil, fmt.Errorf("TranspileChildrenNode failed: void element %s can't have children", node.Key)
+			}
+
+			htmlChildren, err := transpileSiblings(context, child.Children, &htmlNode, true)
+			if err != nil {
+				return nil, fmt.Errorf("TranspileChildrenNode failed: %w", err)
 			}
-		} else {
-			htmlNode.Children = append(htmlNode.Children, child.Transpile(&htmlNode))
+			htmlNode.Children = append(htmlNode.Children, htmlChildren...)
+			chain = conditionChain{}
+			continue
+		}
+
+		if isVoid && child.Key == "innerText" {
+			return nil, fmt.Errorf("TranspileChildrenNode failed: void element %s can't have innerText", node.Key)
+		}
+
+		htmlChildren, err := chain.transpile(context, child, &htmlNode, false)
+		if err != nil {
+			return nil, fmt.Errorf("TranspileChildrenNode failed: %w", err)
 		}
+		htmlNode.Children = append(htmlNode.Children, htmlChildren...)
 	}
 
-	return &htmlNode
+	return &htmlNode, nil
+}
+
+// Wraps a single transpiled node in a list, which is empty if the node is nil.
+func toNodeList(node *HtmlNode, err error) ([]*HtmlNode, error) {
+	if err != nil || node == nil {
+		return []*HtmlNode{}, err
+	}
+
+	return []*HtmlNode{node}, nil
 }
 
 // Determines the type of a node based on its content.
-func (node *YamlNode) Transpile(parent *HtmlNode) *HtmlNode {
-	switch node.Type {
-	case RAW_YAML_NODE:
-		return node.transpileRawNode(parent)
+//
+// Returns an error if the node can't be represented in html, such as a void element with children,
+// or if it results in more than one node, like an each: loop. Returns nil if the node has no output,
+// such as an attribute set to false.
+//
+// Expressions are evaluated without data, so any expression that needs data is an error.
+// Use a Template to render with data.
+func (node *YamlNode) Transpile(parent *HtmlNode) (*HtmlNode, error) {
+	asElement := parent == nil || node.Parent != nil && node.Parent.Key == "children"
 
-	case CHILDREN_YAML_NODE:
-		return node.transpileChildrenNode(parent)
+	htmlNodes, err := node.transpile(&transpileContext{functions: _STANDARD_FUNCTIONS}, parent, asElement)
+	if err != nil {
+		return nil, err
+	}
+
+	switch len(htmlNodes) {
+	case 0:
+		return nil, nil
+	case 1:
+		return htmlNodes[0], nil
 	default:
-		return &HtmlNode{
-			Type:   UNKNOWN_HTML_NODE,
-			Parent: parent,
-		}
+		return nil, fmt.Errorf("Transpile failed: %s results in %d nodes, use a Template to render it", node.Key, len(htmlNodes))
 	}
 }
 
-// Converts an HTML node to a string.
-func (node *HtmlNode) String() string {
-	switch node.Type {
-	case RAW_HTML_NODE:
-		return node.Content
-	case TAG_HTML_NODE:
-		attributes := ""
-		children := ""
-
-		for _, node := range node.Children {
-			if node.Type == ATTRIBUTE_HTML_NODE {
-				attributes += " " + node.Attribute + "=\"" + node.Content + "\""
-			} else {
-				children += node.String()
-			}
+// Transpiles a node with the expressions in it evaluated. Its children are transpiled in the same context.
+//
+// As an element, the node is a tag or text. Otherwise it's an attribute, or the innerText of its parent.
+// A node can result in any number of html nodes, as loops and conditions are spliced into their parent.
+func (node *YamlNode) transpile(context *transpileContext, parent *HtmlNode, asElement bool) ([]*HtmlNode, error) {
+	if declared := context.components[node.Key]; declared != nil && asElement {
+		return node.transpileComponent(context, declared, parent)
+	}
+
+	if node.isSlot() && context.call != nil {
+		return node.transpileComponentSlot(context, parent, asElement)
+	}
+
+	if node.Type == CHILDREN_YAML_NODE {
+		switch {
+		case node.Key == "each":
+			return node.transpileEach(context, parent, asElement)
+		case _CONDITION_KEYS[node.Key]:
+			// A condition on its own, outside of a list of siblings.
+			var chain conditionChain
+			return chain.transpile(context, node, parent, asElement)
+		case !asElement && _ATTRIBUTE_GROUPS[node.Key]:
+			return node.transpileAttributeGroup(context, parent)
+		case !asElement && _STRUCTURED_ATTRIBUTES[node.Key] != nil:
+			return toNodeList(node.transpileStructuredAttribute(context, parent))
 		}
+	}
 
-		return "<" + node.Tag + attributes + ">" + children + "</" + node.Tag + ">"
-	case ATTRIBUTE_HTML_NODE:
-		return node.Attribute + "=\"" + node.Content + "\""
+	node, err := context.resolve(node, func(key string) valueLanguage {
+		return contentLanguage(key, parent, asElement)
+	})
+	if err != nil {
+		return nil, fmt.Errorf("Transpile failed: %w", err)
+	}
+
+	switch node.Type {
+	case RAW_YAML_NODE:
+		return toNodeList(node.transpileRawNode(parent, asElement))
+	case CHILDREN_YAML_NODE:
+		return toNodeList(node.transpileChildrenNode(context, parent))
 	default:
-		return ""
+		return []*HtmlNode{{
+			Type:   UNKNOWN_HTML_NODE,
+			Parent: parent,
+		}}, nil
 	}
 }
@@ -2,6 +2,7 @@ package yaml_tmpl_test
 
 import (
 	"fmt"
+	"strings"
 	"testing"
 
 	"github.com/frodi-karlsson/yaml_tmpl"
@@ -50,9 +51,12 @@ func getSimpleChildrenHtmlNode() yaml_tmpl.YamlNode {
 func TestParseSimpleRawTagNode(t *testing.T) {
 	SIMPLE_RAW_TAG_HTML_NODE := getSimpleRawTagHtmlNode()
 
-	transpiled := SIMPLE_RAW_TAG_HTML_NODE.Transpile(nil)
+	transpiled, err := SIMPLE_RAW_TAG_HTML_NODE.Transpile(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
 
-	res, err := expectHtmlNodeToEqual(t, *transpiled, yaml_tmpl.HtmlNode{
+	res, msg := expectHtmlNodeToEqual(t, *transpiled, yaml_tmpl.HtmlNode{
 		Type: yaml_tmpl.TAG_HTML_NODE,
 		Tag:  "tag",
 		Children: []*yaml_tmpl.HtmlNode{
@@ -66,14 +70,17 @@ func TestParseSimpleRawTagNode(t *testing.T) {
 	})
 
 	if !res {
-		t.Errorf("Got unexpected result: %s for:\n%v", err, transpiled)
+		t.Errorf("Got unexpected result: %s for:\n%v", msg, transpiled)
 	}
 }
 
 func TestPrintSimpleHtmlRawTagNode(t *testing.T) {
 	SIMPLE_RAW_TAG_HTML_NODE := getSimpleRawTagHtmlNode()
 
-	transpiled := SIMPLE_RAW_TAG_HTML_NODE.Transpile(nil)
+	transpiled, err := SIMPLE_RAW_TAG_HTML_NODE.Transpile(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
 	html := transpiled.String()
 	expected := "<tag>value</tag>"
 	if html != expected {
@@ -84,8 +91,11 @@ func TestPrintSimpleHtmlRawTagNode(t *testing.T) {
 func TestParseSimpleHtmlChildrenNode(t *testing.T) {
 	SIMPLE_CHILDREN_HTML_NODE := getSimpleChildrenHtmlNode()
 
-	transpiled := SIMPLE_CHILDREN_HTML_NODE.Transpile(nil)
-	res, err := expectHtmlNodeToEqual(t, *transpiled, yaml_tmpl.HtmlNode{
+	transpiled, err := SIMPLE_CHILDREN_HTML_NODE.Transpile(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	res, msg := expectHtmlNodeToEqual(t, *transpiled, yaml_tmpl.HtmlNode{
 		Type: yaml_tmpl.TAG_HTML_NODE,
 		Tag:  "tag",
 		Children: []*yaml_tmpl.HtmlNode{
@@ -107,8 +117,331 @@ func TestParseSimpleHtmlChildrenNode(t *testing.T) {
 	})
 
 	if !res {
-		t.Errorf("Got unexpected result: %s for:\n%v", err, transpiled)
+		t.Errorf("Got unexpected result: %s for:\n%v", msg, transpiled)
+	}
+}
+
+func TestPrintEscapesTextContent(t *testing.T) {
+	html, err := transpileLines(t, []string{
+		"p: \"a < b && c > d\"",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := "<p>a &lt; b &amp;&amp; c &gt; d</p>"
+	if html != expected {
+		t.Errorf("Expected %s, got %s", expected, html)
+	}
+}
+
+func TestPrintDoesNotEscapeRawContent(t *testing.T) {
+	html, err := transpileLines(t, []string{
+		"p:",
+		"  children:",
+		"    - raw: \"<b>bold</b>\"",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := "<p><b>bold</b></p>"
+	if html != expected {
+		t.Errorf("Expected %s, got %s", expected, html)
+	}
+}
+
+func TestPrintEscapesAttributeValues(t *testing.T) {
+	html, err := transpileLines(t, []string{
+		"div:",
+		"  title: \"\\\" onclick=\\\"alert(1)\"",
+		"  innerText: \"<script>\"",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := "<div title=\"&#34; onclick=&#34;alert(1)\">&lt;script&gt;</div>"
+	if html != expected {
+		t.Errorf("Expected %s, got %s", expected, html)
+	}
+}
+
+func TestPrintFiltersUnsafeURLs(t *testing.T) {
+	html, err := transpileLines(t, []string{
+		"a:",
+		"  href: \"javascript:alert(1)\"",
+		"  innerText: \"link\"",
+		"img:",
+		"  src: \"/images/a cat.png?size=big\"",
+		"source:",
+		"  srcset: \"small.png 1x,javascript:alert(1) 2x, /large.png  3x\"",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := "<a href=\"about:invalid#unsafe-url\">link</a>" +
+		"<img src=\"/images/a%20cat.png?size=big\">" +
+		"<source srcset=\"small.png 1x, about:invalid#unsafe-url 2x, /large.png 3x\">"
+	if html != expected {
+		t.Errorf("Expected %s, got %s", expected, html)
+	}
+}
+
+func TestPrintEscapesScriptContent(t *testing.T) {
+	html, err := transpileLines(t, []string{
+		"script: \"if (a < b) { document.write(\\\"</SCRIPT><b>\\\") }\"",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := "<script>if (a < b) { document.write(\"<\\/SCRIPT><b>\") }</script>"
+	if html != expected {
+		t.Errorf("Expected %s, got %s", expected, html)
+	}
+}
+
+func TestPrintVoidElements(t *testing.T) {
+	html, err := transpileLines(t, []string{
+		"head:",
+		"  children:",
+		"    - meta:",
+		"        charset: \"utf-8\"",
+		"    - link:",
+		"        rel: \"stylesheet\"",
+		"        href: \"style.css\"",
+		"br: \"\"",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := "<head><meta charset=\"utf-8\"><link rel=\"stylesheet\" href=\"style.css\"></head><br>"
+	if html != expected {
+		t.Errorf("Expected %s, got %s", expected, html)
+	}
+}
+
+func TestTranspileVoidElementWithChildren(t *testing.T) {
+	_, err := transpileLines(t, []string{
+		"img:",
+		"  src: \"a.png\"",
+		"  children:",
+		"    - p: \"value\"",
+	})
+	if err == nil {
+		t.Error("Expected an error for a void element with children")
+	}
+}
+
+func TestTranspileVoidElementWithInnerText(t *testing.T) {
+	_, err := transpileLines(t, []string{
+		"input:",
+		"  innerText: \"value\"",
+	})
+	if err == nil {
+		t.Error("Expected an error for a void element with innerText")
+	}
+
+	_, err = transpileLines(t, []string{
+		"br: \"value\"",
+	})
+	if err == nil {
+		t.Error("Expected an error for a void element with content")
+	}
+}
+
+func TestPrintBlockScalarContent(t *testing.T) {
+	html, err := transpileLines(t, []string{
+		"pre:",
+		"  class: \"code\"",
+		"  innerText: |",
+		"    if a < b:",
+		"        print(\"yes\")",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := "<pre class=\"code\">if a &lt; b:\n    print(\"yes\")\n</pre>"
+	if html != expected {
+		t.Errorf("Expected %s, got %s", expected, html)
+	}
+}
+
+func TestPrintFlowCollections(t *testing.T) {
+	html, err := transpileLines(t, []string{
+		"p:",
+		"  class: intro",
+		"  children: [\"Some \", b: bold, \" text\", img: {src: \"a.png\", alt: A}]",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := "<p class=\"intro\">Some <b>bold</b> text<img src=\"a.png\" alt=\"A\"></p>"
+	if html != expected {
+		t.Errorf("Expected %s, got %s", expected, html)
+	}
+}
+
+func TestTranspileListWithoutTag(t *testing.T) {
+	tests := [][]string{
+		{"ul: {children: [[a, b]]}"},
+		{"ul:", "  children:", "    - [a, b]"},
+	}
+
+	for _, lines := range tests {
+		_, err := transpileLines(t, lines)
+		if err == nil || !strings.Contains(err.Error(), "needs a tag to be an element") {
+			t.Errorf("Expected an error for a list without a tag in %v, got %v", lines, err)
+		}
+	}
+}
+
+func TestPrintBooleanAttributes(t *testing.T) {
+	html, err := transpileLines(t, []string{
+		"input:",
+		"  type: checkbox",
+		"  checked: true",
+		"  disabled: false",
+		"  required: null",
+		"  readonly: ~",
+		"  hidden:",
+		"  value: \"true\"",
+		"  title: 'false'",
+		"script: {src: \"a.js\", defer: TRUE, async: False}",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := "<input type=\"checkbox\" checked value=\"true\" title=\"false\"><script src=\"a.js\" defer></script>"
+	if html != expected {
+		t.Errorf("Expected %s, got %s", expected, html)
+	}
+}
+
+func TestPrintBooleanValuesAsText(t *testing.T) {
+	html, err := transpileLines(t, []string{
+		"p: true",
+		"span:",
+		"  innerText: false",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := "<p>true</p><span>false</span>"
+	if html != expected {
+		t.Errorf("Expected %s, got %s", expected, html)
+	}
+}
+
+func TestPrintClassLists(t *testing.T) {
+	html, err := transpileLines(t, []string{
+		"button:",
+		"  class:",
+		"    - btn",
+		"    - \"btn-primary  btn\"",
+		"    - active: true",
+		"    - disabled: false",
+		"    - [large, btn-primary]",
+		"  innerText: \"Save\"",
+		"div:",
+		"  class: {hidden: false}",
+		"span:",
+		"  class: \"kept  as is\"",
+	})
+	if err != nil {
+		t.Fatal(err)
 	}
+
+	expected := "<button class=\"btn btn-primary active large\">Save</button><div></div><span class=\"kept  as is\"></span>"
+	if html != expected {
+		t.Errorf("Expected %s, got %s", expected, html)
+	}
+}
+
+func TestPrintStyleMaps(t *testing.T) {
+	html, err := transpileLines(t, []string{
+		"div:",
+		"  style:",
+		"    color: red",
+		"    margin-top: 4px",
+		"    display: null",
+		"    - \"color: blue; --gap: 1rem;\"",
+		"    font-family: '\"Fira Code\", monospace'",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := "<div style=\"color: blue; margin-top: 4px; --gap: 1rem; font-family: &#34;Fira Code&#34;, monospace\"></div>"
+	if html != expected {
+		t.Errorf("Expected %s, got %s", expected, html)
+	}
+}
+
+func TestPrintAttributeGroups(t *testing.T) {
+	html, err := transpileLines(t, []string{
+		"button:",
+		"  data:",
+		"    userId: 5",
+		"    track_event: save",
+		"    modal: {targetID: dialog, open: false}",
+		"    empty: null",
+		"  aria: {label: \"Save the form\", hidden: true}",
+		"  innerText: \"Save\"",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := "<button data-user-id=\"5\" data-track-event=\"save\" data-modal-target-id=\"dialog\" data-modal-open=\"false\" " +
+		"aria-label=\"Save the form\" aria-hidden=\"true\">Save</button>"
+	if html != expected {
+		t.Errorf("Expected %s, got %s", expected, html)
+	}
+}
+
+func TestTranspileInvalidStructuredAttributes(t *testing.T) {
+	invalid := [][]string{
+		{"div:", "  class: {active: \"yes\"}"},
+		{"div:", "  style: [\"color\"]"},
+		{"div:", "  style:", "    color: [red]"},
+		{"div:", "  data: [a, b]"},
+	}
+
+	for _, lines := range invalid {
+		_, err := transpileLines(t, lines)
+		if err == nil {
+			t.Errorf("Expected an error for %v", lines)
+		}
+	}
+}
+
+// Parses and transpiles lines, returning the resulting HTML.
+func transpileLines(t *testing.T, lines []string) (string, error) {
+	t.Helper()
+
+	nodes, err := yaml_tmpl.GetYamlNodesFromLines(lines)
+	if err != nil {
+		return "", err
+	}
+
+	html := ""
+	for _, node := range nodes {
+		htmlNode, err := node.Transpile(nil)
+		if err != nil {
+			return "", err
+		}
+		html += htmlNode.String()
+	}
+
+	return html, nil
 }
 
 func expectHtmlNodeToEqual(t *testing.T, node yaml_tmpl.HtmlNode, expected yaml_tmpl.HtmlNode) (bool, string) {